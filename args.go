@@ -0,0 +1,125 @@
+package flagga
+
+import "fmt"
+
+type positional struct {
+	name     string
+	usage    string
+	value    *string
+	variadic *[]string
+	min      int
+}
+
+// Positional declares a positional argument with the given name and returns
+// a pointer to the value that will be filled once the flag set is parsed.
+// Once positional arguments are declared, the number of positional arguments
+// given must match them.
+func (fs *FlagSet) Positional(name, usage string) *string {
+	v := new(string)
+	fs.PositionalVar(v, name, usage)
+	return v
+}
+
+// PositionalVar declares a positional argument with the given name. When the
+// flag set is parsed it will fill the given pointer. Once positional
+// arguments are declared, the number of positional arguments given must match
+// them.
+func (fs *FlagSet) PositionalVar(v *string, name, usage string) {
+	fs.positionals = append(fs.positionals, positional{
+		name:  name,
+		usage: usage,
+		value: v,
+	})
+}
+
+// Variadic declares a variadic positional argument with the given name that
+// takes at least min arguments, and returns a pointer to the value that will
+// be filled once the flag set is parsed. The variadic argument takes all the
+// arguments not taken by the positional arguments declared before and after
+// it, so cp <src>... <dst> can be declared with Variadic("src", 1, "")
+// followed by Positional("dst", ""). It panics if a variadic argument was
+// already declared.
+func (fs *FlagSet) Variadic(name string, min int, usage string) *[]string {
+	v := new([]string)
+	fs.VariadicVar(v, name, min, usage)
+	return v
+}
+
+// VariadicVar declares a variadic positional argument with the given name
+// that takes at least min arguments. When the flag set is parsed it will fill
+// the given pointer. It panics if a variadic argument was already declared.
+func (fs *FlagSet) VariadicVar(v *[]string, name string, min int, usage string) {
+	for _, p := range fs.positionals {
+		if p.variadic != nil {
+			panic(fmt.Errorf("variadic argument %s was already declared", p.name))
+		}
+	}
+
+	fs.positionals = append(fs.positionals, positional{
+		name:     name,
+		usage:    usage,
+		variadic: v,
+		min:      min,
+	})
+}
+
+// bindPositionals fills the declared positional arguments with the
+// positional arguments given, checking their number.
+func (fs *FlagSet) bindPositionals() error {
+	if len(fs.positionals) == 0 {
+		return nil
+	}
+
+	fs.positionalArgs = make(map[string]int)
+	var fixed, variadic = 0, -1
+	for i, p := range fs.positionals {
+		if p.variadic != nil {
+			variadic = i
+		} else {
+			fixed++
+		}
+	}
+
+	args := fs.args
+	if variadic < 0 {
+		if len(args) != fixed {
+			return fmt.Errorf(fs.msgs().ArgCount, fixed, len(args))
+		}
+
+		for i, p := range fs.positionals {
+			fs.bindPositional(p, i)
+		}
+		return nil
+	}
+
+	min := fixed + fs.positionals[variadic].min
+	if len(args) < min {
+		return fmt.Errorf(fs.msgs().MinArgCount, min, len(args))
+	}
+
+	// the fixed arguments after the variadic one take the last arguments
+	tail := len(fs.positionals) - variadic - 1
+	for i, p := range fs.positionals[:variadic] {
+		fs.bindPositional(p, i)
+	}
+
+	for i, p := range fs.positionals[variadic+1:] {
+		fs.bindPositional(p, len(args)-tail+i)
+	}
+
+	if variadic < len(args)-tail {
+		fs.positionalArgs[fs.positionals[variadic].name] = variadic
+	}
+
+	rest := args[variadic : len(args)-tail]
+	*fs.positionals[variadic].variadic = make([]string, len(rest))
+	copy(*fs.positionals[variadic].variadic, rest)
+	return nil
+}
+
+// bindPositional fills the given positional argument with the argument at
+// the given index.
+func (fs *FlagSet) bindPositional(p positional, idx int) {
+	*p.value = fs.args[idx]
+	fs.positionalArgs[p.name] = idx
+}
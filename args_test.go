@@ -0,0 +1,85 @@
+package flagga
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestPositionals(t *testing.T) {
+	var fs FlagSet
+	r := fs.Bool("r", "")
+	src := fs.Variadic("src", 1, "")
+	dst := fs.Positional("dst", "")
+
+	err := fs.Parse([]string{"a", "-r", "b", "c", "dir"})
+	expect(t, err, nil)
+	expect(t, *r, true)
+	expect(t, *src, []string{"a", "b", "c"})
+	expect(t, *dst, "dir")
+	expect(t, fs.Args(), []string{"a", "b", "c", "dir"})
+}
+
+func TestPositionalsBinding(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		head     string
+		variadic []string
+		tail     string
+	}{
+		{[]string{"a", "b"}, "a", []string{}, "b"},
+		{[]string{"a", "b", "c"}, "a", []string{"b"}, "c"},
+		{[]string{"a", "b", "c", "d"}, "a", []string{"b", "c"}, "d"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var fs FlagSet
+			head := fs.Positional("head", "")
+			variadic := fs.Variadic("variadic", 0, "")
+			tail := fs.Positional("tail", "")
+
+			expect(t, fs.Parse(tt.args), nil)
+			expect(t, *head, tt.head)
+			expect(t, *variadic, tt.variadic)
+			expect(t, *tail, tt.tail)
+		})
+	}
+}
+
+func TestPositionalsCount(t *testing.T) {
+	testCases := []struct {
+		name     string
+		variadic bool
+		args     []string
+		expected error
+	}{
+		{"fixed", false, []string{"a", "b"}, nil},
+		{"too few fixed", false, []string{"a"}, fmt.Errorf("expecting 2 arguments, got 1")},
+		{"too many fixed", false, []string{"a", "b", "c"}, fmt.Errorf("expecting 2 arguments, got 3")},
+		{"variadic", true, []string{"a", "b", "c"}, nil},
+		{"too few variadic", true, []string{"a", "b"}, fmt.Errorf("expecting at least 3 arguments, got 2")},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fs.Positional("a", "")
+			if tt.variadic {
+				fs.Variadic("v", 1, "")
+			}
+			fs.Positional("b", "")
+
+			expect(t, fs.Parse(tt.args), tt.expected)
+		})
+	}
+
+	var fs FlagSet
+	fs.Variadic("a", 0, "")
+	defer func() {
+		expect(t, recover(), fmt.Errorf("variadic argument a was already declared"))
+	}()
+	fs.Variadic("b", 0, "")
+}
@@ -0,0 +1,170 @@
+package flagga
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EncodeFunc is a function that will encode the given values, keyed by flag
+// name, to the given writer.
+type EncodeFunc func(w io.Writer, values map[string]interface{}) error
+
+var configEncoders = map[string]EncodeFunc{
+	"json": encodeJSON,
+}
+
+// RegisterConfigEncoder registers the function that encodes the config files
+// with the given format (e.g. "yaml") for all the flag sets, so they can be
+// written with WriteConfig. Use SetEncoder to set it for a single flag set.
+func RegisterConfigEncoder(format string, encode EncodeFunc) {
+	configEncoders[strings.ToLower(format)] = encode
+}
+
+func encodeJSON(w io.Writer, values map[string]interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(values)
+}
+
+// SetEncoder sets the function used by WriteConfig to encode the config files
+// of the given format (e.g. "yaml") in this flag set, taking precedence over
+// the ones registered with RegisterConfigEncoder. Encoders for YAML or TOML,
+// such as one wrapping yaml.Marshal of gopkg.in/yaml.v2, must be set this way
+// or registered, as only JSON is built in.
+func (fs *FlagSet) SetEncoder(format string, encode EncodeFunc) {
+	if fs.encoders == nil {
+		fs.encoders = make(map[string]EncodeFunc)
+	}
+	fs.encoders[strings.ToLower(format)] = encode
+}
+
+// SetSecretMask sets the value written by WriteConfig instead of the values
+// of the secret flags. If it's empty, which is the default, secret flags are
+// not written.
+func (fs *FlagSet) SetSecretMask(mask string) { fs.secretMask = mask }
+
+// WriteConfig writes the current values of the flags, keyed by flag name, to
+// the given writer in the given format, so they can be read back later with
+// the source of that format. Only JSON is built in, other formats need an
+// encoder set with SetEncoder or registered with RegisterConfigEncoder.
+// Durations and URLs are written as strings, such as "1m30s", times as
+// RFC3339 strings unless the flag has its own layout, and the values of
+// secret flags are replaced by the mask set with SetSecretMask or not written
+// at all.
+func (fs *FlagSet) WriteConfig(w io.Writer, format string) error {
+	encode, ok := fs.encoders[strings.ToLower(format)]
+	if !ok {
+		encode, ok = configEncoders[strings.ToLower(format)]
+	}
+	if !ok {
+		return fmt.Errorf("unsupported config file format: %s", format)
+	}
+
+	return encode(w, fs.configValues(fs.secretMask, false))
+}
+
+// SaveState writes the values of the flags given in the arguments or found
+// in the sources to the JSON file at the given path, creating its directory
+// if needed, so they can be used on the next run with StateFileVia. Flags
+// with their default value are not written, so changes of the defaults
+// apply to them, and neither are secret flags.
+func (fs *FlagSet) SaveState(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	// the state is written to a temporary file first, so the previous state
+	// is kept if writing fails
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := encodeJSON(f, fs.configValues("", true)); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// configValues returns the values of the flags to be written to a config
+// file, keyed by flag name. Secret flags get the given mask or are left out
+// if it's empty, and so are the flags not given anywhere if onlyGiven is set.
+func (fs *FlagSet) configValues(mask string, onlyGiven bool) map[string]interface{} {
+	var values = make(map[string]interface{}, len(fs.flags))
+	for _, name := range fs.flagOrder {
+		f := fs.flags[name]
+		if _, ok := f.Value.(aliasValue); ok {
+			continue
+		}
+
+		g, ok := f.Value.(Getter)
+		if !ok {
+			continue
+		}
+
+		if o := fs.origins[name]; onlyGiven && o != OriginArgs && o != OriginSource {
+			continue
+		}
+
+		if f.Secret {
+			if mask != "" {
+				values[name] = mask
+			}
+			continue
+		}
+
+		if tv, ok := g.(timeValue); ok {
+			values[name] = tv.t.Format(tv.layout)
+			continue
+		}
+
+		values[name] = configValue(g.Get())
+	}
+
+	return values
+}
+
+// configValue converts the given flag value to a value that can be read back
+// from a config file.
+func configValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case time.Duration:
+		return v.String()
+	case []time.Duration:
+		var values = make([]string, len(v))
+		for i, d := range v {
+			values[i] = d.String()
+		}
+		return values
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case url.URL:
+		return v.String()
+	case *url.URL:
+		return v.String()
+	case []url.URL:
+		var values = make([]string, len(v))
+		for i := range v {
+			values[i] = v[i].String()
+		}
+		return values
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return v
+	}
+}
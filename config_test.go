@@ -0,0 +1,242 @@
+package flagga
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func writeConfigFlagSet() *FlagSet {
+	fs := NewFlagSet("", "", ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.String("host", "localhost", "", JSON("host"), Config("host"))
+	fs.Int("port", 8080, "", JSON("port"), Config("port"))
+	fs.Duration("timeout", 5*time.Second, "", JSON("timeout"), Config("timeout"))
+	fs.String("password", "", "", JSON("password"), Config("password"))
+	fs.Lookup("password").Secret = true
+	return fs
+}
+
+func TestWriteConfigJSON(t *testing.T) {
+	fs := writeConfigFlagSet()
+	err := fs.Parse([]string{"--port=9090", "--timeout=1m30s", "--password=hunter2"})
+	expect(t, err, nil)
+
+	var buf bytes.Buffer
+	expect(t, fs.WriteConfig(&buf, "json"), nil)
+
+	fs = writeConfigFlagSet()
+	err = fs.Parse(nil, ReaderSource(&buf, json.Unmarshal))
+	expect(t, err, nil)
+
+	expect(t, fs.Lookup("host").Value.(Getter).Get(), "localhost")
+	expect(t, fs.Lookup("port").Value.(Getter).Get(), 9090)
+	expect(t, fs.Lookup("timeout").Value.(Getter).Get(), 90*time.Second)
+	expect(t, fs.Lookup("password").Value.(Getter).Get(), "")
+}
+
+func TestWriteConfigSecretMask(t *testing.T) {
+	fs := writeConfigFlagSet()
+	fs.SetSecretMask("***")
+	err := fs.Parse([]string{"--password=hunter2"})
+	expect(t, err, nil)
+
+	var buf bytes.Buffer
+	expect(t, fs.WriteConfig(&buf, "JSON"), nil)
+
+	var values map[string]interface{}
+	expect(t, json.Unmarshal(buf.Bytes(), &values), nil)
+	expect(t, values["password"], "***")
+}
+
+func encodeYAML(w io.Writer, values map[string]interface{}) error {
+	var keys []string
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "%s: %v\n", k, values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestWriteConfigYAML(t *testing.T) {
+	fs := writeConfigFlagSet()
+	err := fs.Parse([]string{"--host=example.com", "--password=hunter2"})
+	expect(t, err, nil)
+
+	var buf bytes.Buffer
+	expect(t, fs.WriteConfig(&buf, "yaml"), fmt.Errorf("unsupported config file format: yaml"))
+
+	RegisterConfigEncoder("yaml", encodeYAML)
+	defer delete(configEncoders, "yaml")
+
+	expect(t, fs.WriteConfig(&buf, "yaml"), nil)
+	expect(t, buf.String(), "host: example.com\nport: 8080\ntimeout: 5s\n")
+
+	fs = writeConfigFlagSet()
+	err = fs.Parse(nil, ReaderSource(&buf, parseFlatYAML))
+	expect(t, err, nil)
+
+	expect(t, fs.Lookup("host").Value.(Getter).Get(), "example.com")
+	expect(t, fs.Lookup("port").Value.(Getter).Get(), 8080)
+	expect(t, fs.Lookup("timeout").Value.(Getter).Get(), 5*time.Second)
+}
+
+func TestWriteConfigSetEncoder(t *testing.T) {
+	fs := writeConfigFlagSet()
+	expect(t, fs.Parse([]string{"--host=example.com"}), nil)
+	fs.SetEncoder("YAML", encodeYAML)
+
+	var buf bytes.Buffer
+	expect(t, fs.WriteConfig(&buf, "yaml"), nil)
+	expect(t, buf.String(), "host: example.com\nport: 8080\ntimeout: 5s\n")
+
+	fs = writeConfigFlagSet()
+	expect(t, fs.Parse(nil), nil)
+	expect(t, fs.WriteConfig(&buf, "yaml"), fmt.Errorf("unsupported config file format: yaml"))
+}
+
+func TestWriteConfigURLAndTime(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "flagga-state")
+	if err != nil {
+		t.Fatalf("unexpected error creating dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	urlFlagSet := func() *FlagSet {
+		fs := NewFlagSet("", "", ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		fs.URL("endpoint", url.URL{}, "")
+		fs.URLList("mirrors", nil, "")
+		fs.Time("since", time.Time{}, "", "")
+		return fs
+	}
+
+	fs := urlFlagSet()
+	err = fs.Parse([]string{
+		"--endpoint=https://example.com/api",
+		"--mirrors=https://a.example.com,https://b.example.com",
+		"--since=2024-03-01T10:00:00Z",
+	})
+	expect(t, err, nil)
+
+	var buf bytes.Buffer
+	expect(t, fs.WriteConfig(&buf, "json"), nil)
+	var values map[string]interface{}
+	expect(t, json.Unmarshal(buf.Bytes(), &values), nil)
+	expect(t, values, map[string]interface{}{
+		"endpoint": "https://example.com/api",
+		"mirrors":  []interface{}{"https://a.example.com", "https://b.example.com"},
+		"since":    "2024-03-01T10:00:00Z",
+	})
+
+	expect(t, fs.SaveState(path), nil)
+
+	fs = urlFlagSet()
+	expect(t, fs.Parse(nil, StateFileVia(path)), nil)
+	u := fs.Lookup("endpoint").Value.(Getter).Get().(url.URL)
+	expect(t, u.String(), "https://example.com/api")
+	mirrors := fs.Lookup("mirrors").Value.(Getter).Get().([]url.URL)
+	expect(t, len(mirrors), 2)
+	expect(t, mirrors[1].String(), "https://b.example.com")
+	expect(t, fs.Lookup("since").Value.(Getter).Get(), time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+
+	since := time.Date(2024, 3, 1, 10, 0, 0, 0, time.FixedZone("", 3600))
+	expect(t, configValue(since), "2024-03-01T10:00:00+01:00")
+	expect(t, configValue(&u), "https://example.com/api")
+}
+
+func TestWriteConfigTime(t *testing.T) {
+	var fs FlagSet
+	fs.Time("day", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), "", "02/01/2006")
+	expect(t, fs.Parse(nil), nil)
+
+	var buf bytes.Buffer
+	expect(t, fs.WriteConfig(&buf, "json"), nil)
+	expect(t, buf.String(), "{\n  \"day\": \"01/03/2024\"\n}\n")
+}
+
+func TestSaveState(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "flagga-state")
+	if err != nil {
+		t.Fatalf("unexpected error creating dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app", "state.json")
+	stateFlagSet := func() *FlagSet {
+		fs := writeConfigFlagSet()
+		fs.StringList("tags", []string{"default"}, "")
+		return fs
+	}
+
+	fs := stateFlagSet()
+	expect(t, fs.Parse(nil, StateFileVia(path)), nil)
+	expect(t, fs.Lookup("host").Value.(Getter).Get(), "localhost")
+
+	err = fs.SaveState(path)
+	expect(t, err, nil)
+	content, err := ioutil.ReadFile(path)
+	expect(t, err, nil)
+	expect(t, string(content), "{}\n")
+
+	fs = stateFlagSet()
+	err = fs.Parse([]string{
+		"--host=example.com",
+		"--timeout=1m30s",
+		"--password=hunter2",
+		"--tags", "a", "--tags", "b",
+	})
+	expect(t, err, nil)
+	expect(t, fs.SaveState(path), nil)
+
+	var values map[string]interface{}
+	content, err = ioutil.ReadFile(path)
+	expect(t, err, nil)
+	expect(t, json.Unmarshal(content, &values), nil)
+	expect(t, values, map[string]interface{}{
+		"host":    "example.com",
+		"timeout": "1m30s",
+		"tags":    []interface{}{"a", "b"},
+	})
+
+	os.Setenv("STATE_PORT", "9090")
+	defer os.Unsetenv("STATE_PORT")
+
+	fs = stateFlagSet()
+	fs.Lookup("host").Extractors = []Extractor{Env("STATE_HOST")}
+	fs.Lookup("port").Extractors = []Extractor{Env("STATE_PORT")}
+	err = fs.Parse([]string{"--timeout=5m"}, EnvPrefix(""), StateFileVia(path))
+	expect(t, err, nil)
+	expect(t, fs.Lookup("host").Value.(Getter).Get(), "example.com")
+	expect(t, fs.Lookup("port").Value.(Getter).Get(), 9090)
+	expect(t, fs.Lookup("timeout").Value.(Getter).Get(), 5*time.Minute)
+	expect(t, fs.Lookup("tags").Value.(Getter).Get(), []string{"a", "b"})
+	expect(t, fs.Lookup("password").Value.(Getter).Get(), "")
+
+	var host string
+	ok, err := JSON("host").Get([]Source{StateFileVia(path)}, NewValue(&host))
+	expect(t, err, nil)
+	expect(t, ok, false)
+
+	if err := ioutil.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+	fs = stateFlagSet()
+	fs.SetOutput(ioutil.Discard)
+	expect(t, fs.Parse(nil, StateFileVia(path)) != nil, true)
+}
@@ -0,0 +1,117 @@
+package flagga
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// evalExpr evaluates an arithmetic expression with integer and float
+// numbers, such as 2*4 or (10+5)/3, and returns the result formatted as a
+// number. Only the +, -, *, / and % operators and parentheses are allowed.
+// If integer is true, the result must be an integer, and divisions between
+// integers are integer divisions.
+func evalExpr(expr string, integer bool) (string, error) {
+	// plain numbers are left as they are to be parsed by the flag
+	if _, err := strconv.ParseFloat(expr, 64); err == nil {
+		return expr, nil
+	}
+
+	node, err := parser.ParseExpr(expr)
+	if err != nil {
+		return "", fmt.Errorf("invalid expression %q", expr)
+	}
+
+	v, err := evalNode(node, integer)
+	if err != nil {
+		return "", fmt.Errorf("invalid expression %q: %s", expr, err)
+	}
+
+	if integer {
+		v = constant.ToInt(v)
+		if v.Kind() != constant.Int {
+			return "", fmt.Errorf("invalid expression %q: result is not an integer", expr)
+		}
+		return v.ExactString(), nil
+	}
+
+	f, _ := constant.Float64Val(v)
+	return strconv.FormatFloat(f, 'g', -1, 64), nil
+}
+
+func evalNode(node ast.Expr, integer bool) (constant.Value, error) {
+	switch n := node.(type) {
+	case *ast.BasicLit:
+		// digits can be grouped with underscores, as in the plain numbers
+		lit := stripDigitSeparators(n.Value, '_')
+		if n.Kind == token.INT {
+			// integers are always decimal, so 010 is 10 and not an octal
+			if strings.Trim(lit, "0123456789") != "" {
+				return nil, fmt.Errorf("unexpected %s", lit)
+			}
+
+			if lit = strings.TrimLeft(lit, "0"); lit == "" {
+				lit = "0"
+			}
+		}
+
+		v := constant.MakeFromLiteral(lit, n.Kind, 0)
+		if n.Kind != token.INT && n.Kind != token.FLOAT || v.Kind() == constant.Unknown {
+			return nil, fmt.Errorf("unexpected %s", n.Value)
+		}
+		return v, nil
+	case *ast.ParenExpr:
+		return evalNode(n.X, integer)
+	case *ast.UnaryExpr:
+		if n.Op != token.ADD && n.Op != token.SUB {
+			return nil, fmt.Errorf("unexpected operator %s", n.Op)
+		}
+
+		x, err := evalNode(n.X, integer)
+		if err != nil {
+			return nil, err
+		}
+		return constant.UnaryOp(n.Op, x, 0), nil
+	case *ast.BinaryExpr:
+		x, err := evalNode(n.X, integer)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := evalNode(n.Y, integer)
+		if err != nil {
+			return nil, err
+		}
+
+		op := n.Op
+		switch op {
+		case token.ADD, token.SUB, token.MUL:
+		case token.QUO:
+			if constant.Sign(y) == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+
+			if integer && x.Kind() == constant.Int && y.Kind() == constant.Int {
+				op = token.QUO_ASSIGN
+			}
+		case token.REM:
+			if x.Kind() != constant.Int || y.Kind() != constant.Int {
+				return nil, fmt.Errorf("%% requires integers")
+			}
+
+			if constant.Sign(y) == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+		default:
+			return nil, fmt.Errorf("unexpected operator %s", op)
+		}
+
+		return constant.BinaryOp(x, op, y), nil
+	default:
+		return nil, fmt.Errorf("only numbers and arithmetic operators are allowed")
+	}
+}
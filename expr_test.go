@@ -0,0 +1,46 @@
+package flagga
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEvalExpr(t *testing.T) {
+	testCases := []struct {
+		expr     string
+		integer  bool
+		expected string
+		err      error
+	}{
+		{"2*4", true, "8", nil},
+		{"10+5", true, "15", nil},
+		{"30*1000", true, "30000", nil},
+		{"-(2+3)*4", true, "-20", nil},
+		{"7/2", true, "3", nil},
+		{"7%4", true, "3", nil},
+		{"010+1", true, "11", nil},
+		{"010", true, "010", nil},
+		{"1_000*2", true, "2000", nil},
+		{"1_000", true, "1_000", nil},
+		{"1_000.5*2", false, "2001", nil},
+		{"7/2", false, "3.5", nil},
+		{"1.5*2", false, "3", nil},
+		{"1.5*2", true, "3", nil},
+		{"1.5*3", true, "", fmt.Errorf(`invalid expression "1.5*3": result is not an integer`)},
+		{"1/0", true, "", fmt.Errorf(`invalid expression "1/0": division by zero`)},
+		{"os.Exit(1)", true, "", fmt.Errorf(`invalid expression "os.Exit(1)": only numbers and arithmetic operators are allowed`)},
+		{"x*2", true, "", fmt.Errorf(`invalid expression "x*2": only numbers and arithmetic operators are allowed`)},
+		{"1<<62", true, "", fmt.Errorf(`invalid expression "1<<62": unexpected operator <<`)},
+		{"0x10", true, "", fmt.Errorf(`invalid expression "0x10": unexpected 0x10`)},
+		{`"a"+"b"`, true, "", fmt.Errorf(`invalid expression "\"a\"+\"b\"": unexpected "a"`)},
+		{"2*", true, "", fmt.Errorf(`invalid expression "2*"`)},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.expr, func(t *testing.T) {
+			result, err := evalExpr(tt.expr, tt.integer)
+			expect(t, err, tt.err)
+			expect(t, result, tt.expected)
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package flagga
 
+import "fmt"
+
 // Extractor extracts values from the sources to fill the flag value.
 type Extractor interface {
 	// Get checks the sources and tries to assign the flag value.
@@ -7,41 +9,200 @@ type Extractor interface {
 }
 
 // Env returns an Extractor that will match environment variables with
-// the given key.
+// the given key. All the provided environment sources are checked in order.
 func Env(key string) Extractor {
-	return envExtractor(key)
+	return KindExtractor(EnvKind, key)
+}
+
+// EnvAny returns an Extractor that will match the first of the given
+// environment variables that is set, so variables can be renamed keeping the
+// old names as a fallback, such as EnvAny("NEW_NAME", "OLD_NAME"). Each key
+// is checked in all the provided environment sources before trying the next
+// one.
+func EnvAny(keys ...string) Extractor {
+	return anyEnvExtractor(keys)
+}
+
+type anyEnvExtractor []string
+
+func (e anyEnvExtractor) Get(sources []Source, dst Value) (bool, error) {
+	for _, key := range e {
+		ok, err := Env(key).Get(sources, dst)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	return false, nil
 }
 
-type envExtractor string
+// EnvWithPrefix returns an Extractor that will match the environment variable
+// with the given prefix and key, instead of using the prefix of the provided
+// environment sources. The environment is only checked if an environment
+// source is provided. Other environment sources, such as the ones created by
+// EnvFileVia, are checked for the key with the given prefix.
+func EnvWithPrefix(prefix, key string) Extractor {
+	return prefixedEnvExtractor{prefix, key}
+}
 
-func (e envExtractor) Get(sources []Source, dst Value) (bool, error) {
+type prefixedEnvExtractor struct {
+	prefix string
+	key    string
+}
+
+func (e prefixedEnvExtractor) Get(sources []Source, dst Value) (bool, error) {
 	for _, s := range sources {
-		if _, ok := s.(envSource); !ok {
+		if KindOf(s) != EnvKind {
 			continue
 		}
 
-		ok, err := s.Get(string(e), dst)
-		if err != nil || !ok {
-			return false, err
+		var ok bool
+		var err error
+		if _, isEnv := s.(envSource); isEnv {
+			ok, err = envSource(e.prefix).Get(e.key, dst)
+		} else {
+			ok, err = s.Get(e.prefix+e.key, dst)
 		}
 
-		return true, nil
+		if err != nil || ok {
+			return ok, err
+		}
 	}
 
 	return false, nil
 }
 
-type jsonExtractor string
+// EnvIndexed returns an Extractor for list flags that will match the
+// environment variables with the given base name followed by an underscore
+// and an index starting at zero, such as ITEM_0, ITEM_1 and ITEM_2, until
+// the first missing index. The values of all of them are the values of the
+// list. All the provided environment sources are checked in order, and the
+// first one with the variable of index zero provides all the values.
+func EnvIndexed(base string) Extractor {
+	return indexedEnvExtractor(base)
+}
+
+type indexedEnvExtractor string
+
+func (e indexedEnvExtractor) Get(sources []Source, dst Value) (bool, error) {
+	for _, s := range sources {
+		if KindOf(s) != EnvKind {
+			continue
+		}
+
+		var values []string
+		for i := 0; ; i++ {
+			var v string
+			ok, err := s.Get(fmt.Sprintf("%s_%d", e, i), NewValue(&v))
+			if err != nil {
+				return false, err
+			}
+
+			if !ok {
+				break
+			}
+			values = append(values, v)
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		if err := dst.Set(values); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
 
 // JSON returns an Extractor that will match the given key in a provided
 // JSON file to set as value for the flag.
 func JSON(key string) Extractor {
-	return jsonExtractor(key)
+	return KindExtractor(JSONKind, key)
+}
+
+// YAML returns an Extractor that will match the given key in a provided
+// YAML file to set as value for the flag.
+func YAML(key string) Extractor {
+	return KindExtractor(YAMLKind, key)
+}
+
+// TOML returns an Extractor that will match the given key in a provided
+// TOML file to set as value for the flag.
+func TOML(key string) Extractor {
+	return KindExtractor(TOMLKind, key)
+}
+
+// Registry returns an Extractor that will match the given value name in the
+// provided Windows registry sources.
+func Registry(name string) Extractor {
+	return KindExtractor(RegistryKind, name)
+}
+
+// KindExtractor returns an Extractor that will match the given key in the
+// provided sources of the given kind. The value is taken from the first
+// source of that kind containing the key.
+func KindExtractor(kind, key string) Extractor {
+	return kindExtractor{kind, key}
+}
+
+type kindExtractor struct {
+	kind string
+	key  string
+}
+
+func (e kindExtractor) Get(sources []Source, dst Value) (bool, error) {
+	for _, s := range sources {
+		if KindOf(s) != e.kind {
+			continue
+		}
+
+		ok, err := s.Get(e.key, dst)
+		if err != nil {
+			return false, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		return true, nil
+	}
+
+	return false, nil
 }
 
-func (e jsonExtractor) Get(sources []Source, dst Value) (bool, error) {
+// prefixKey returns the given extractor with the given prefix prepended to
+// its key if it's an extractor of config files.
+func prefixKey(e Extractor, prefix string) Extractor {
+	switch e := e.(type) {
+	case kindExtractor:
+		if e.kind == EnvKind || e.kind == RegistryKind {
+			return e
+		}
+		return kindExtractor{e.kind, prefix + e.key}
+	case configExtractor:
+		return configExtractor(prefix + string(e))
+	default:
+		return e
+	}
+}
+
+// Config returns an Extractor that will match the given key in any of the
+// provided sources built on top of a FileSource, no matter the format of
+// their files. Sources defined in other packages will be matched as long as
+// they embed *FileSource.
+func Config(key string) Extractor {
+	return configExtractor(key)
+}
+
+type configExtractor string
+
+func (e configExtractor) Get(sources []Source, dst Value) (bool, error) {
 	for _, s := range sources {
-		if _, ok := s.(*jsonSource); !ok {
+		if _, ok := s.(configSource); !ok {
 			continue
 		}
 
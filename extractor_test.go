@@ -1,8 +1,12 @@
 package flagga
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -40,6 +44,144 @@ func TestEnv(t *testing.T) {
 	}
 }
 
+func TestEnvMultipleSources(t *testing.T) {
+	os.Setenv("TEST_ONLY_IN_ENV", "env")
+	defer os.Unsetenv("TEST_ONLY_IN_ENV")
+
+	file := &envFileSource{&FileSource{Value: map[string]interface{}{
+		"ONLY_IN_FILE": "file",
+		"ONLY_IN_ENV":  "file",
+	}}}
+	sources := []Source{EnvPrefix("TEST_"), file}
+
+	var s string
+	ok, err := Env("ONLY_IN_FILE").Get(sources, NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, s, "file")
+
+	ok, err = Env("ONLY_IN_ENV").Get(sources, NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, s, "env")
+}
+
+func TestEnvWithPrefix(t *testing.T) {
+	os.Setenv("APP_DB_HOST", "app_host")
+	os.Setenv("LEGACY_DB_HOST", "legacy_host")
+	os.Setenv("APP_DB_PORT", "1234")
+	defer os.Unsetenv("APP_DB_HOST")
+	defer os.Unsetenv("LEGACY_DB_HOST")
+	defer os.Unsetenv("APP_DB_PORT")
+
+	var fs FlagSet
+	host := fs.String("host", "", "", EnvWithPrefix("LEGACY_", "DB_HOST"))
+	port := fs.Int("port", 0, "", Env("DB_PORT"))
+	user := fs.String("user", "root", "", EnvWithPrefix("LEGACY_", "DB_USER"))
+
+	expect(t, fs.Parse(nil, EnvPrefix("APP_")), nil)
+	expect(t, *host, "legacy_host")
+	expect(t, *port, 1234)
+	expect(t, *user, "root")
+
+	var s string
+	ok, err := EnvWithPrefix("LEGACY_", "DB_HOST").Get(nil, NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, false)
+}
+
+func TestEnvWithPrefixEnvFile(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "flagga-env")
+	if err != nil {
+		t.Fatalf("unexpected error creating file: %s", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("LEGACY_DB_HOST=file_host\nDB_USER=file_user\n")
+	f.Close()
+
+	os.Setenv("LEGACY_DB_USER", "env_user")
+	defer os.Unsetenv("LEGACY_DB_USER")
+
+	var fs FlagSet
+	host := fs.String("host", "", "", EnvWithPrefix("LEGACY_", "DB_HOST"))
+	user := fs.String("user", "root", "", EnvWithPrefix("LEGACY_", "DB_USER"))
+
+	expect(t, fs.Parse(nil, EnvFileVia(f.Name())), nil)
+	expect(t, *host, "file_host")
+	expect(t, *user, "root")
+
+	var fs2 FlagSet
+	host = fs2.String("host", "", "", EnvWithPrefix("LEGACY_", "DB_HOST"))
+	user = fs2.String("user", "root", "", EnvWithPrefix("LEGACY_", "DB_USER"))
+
+	expect(t, fs2.Parse(nil, EnvPrefix("APP_"), EnvFileVia(f.Name())), nil)
+	expect(t, *host, "file_host")
+	expect(t, *user, "env_user")
+}
+
+func TestEnvIndexed(t *testing.T) {
+	for i, v := range []string{"a", "b", "c"} {
+		os.Setenv(fmt.Sprintf("INDEXED_ITEM_%d", i), v)
+		defer os.Unsetenv(fmt.Sprintf("INDEXED_ITEM_%d", i))
+	}
+	os.Setenv("INDEXED_ITEM_4", "e")
+	defer os.Unsetenv("INDEXED_ITEM_4")
+
+	sources := []Source{JSONVia("config.json"), EnvPrefix("INDEXED_")}
+
+	var items = []string{"default"}
+	ok, err := EnvIndexed("ITEM").Get(sources, NewValue(&items))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, items, []string{"a", "b", "c"})
+
+	var other []string
+	ok, err = EnvIndexed("OTHER").Get(sources, NewValue(&other))
+	expect(t, err, nil)
+	expect(t, ok, false)
+	expect(t, other, []string(nil))
+
+	var fs FlagSet
+	ports := fs.IntList("ports", []int{80}, "", EnvIndexed("PORT"))
+	os.Setenv("INDEXED_PORT_0", "8080")
+	os.Setenv("INDEXED_PORT_1", "9090")
+	defer os.Unsetenv("INDEXED_PORT_0")
+	defer os.Unsetenv("INDEXED_PORT_1")
+
+	expect(t, fs.Parse(nil, EnvPrefix("INDEXED_")), nil)
+	expect(t, *ports, []int{8080, 9090})
+}
+
+func TestEnvAny(t *testing.T) {
+	os.Setenv("ANY_OLD_NAME", "old")
+	os.Setenv("ANY_LEGACY_NAME", "legacy")
+	defer os.Unsetenv("ANY_OLD_NAME")
+	defer os.Unsetenv("ANY_LEGACY_NAME")
+
+	sources := []Source{JSONVia("config.json"), EnvPrefix("ANY_")}
+
+	var s string
+	ok, err := EnvAny("NEW_NAME", "OLD_NAME", "LEGACY_NAME").Get(sources, NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, s, "old")
+
+	s = "default"
+	ok, err = EnvAny("NEW_NAME", "OTHER_NAME").Get(sources, NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, false)
+	expect(t, s, "default")
+
+	ok, err = EnvAny().Get(sources, NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, false)
+
+	var n int
+	ok, err = EnvAny("OLD_NAME").Get(sources, NewValue(&n))
+	expect(t, err != nil, true)
+	expect(t, ok, false)
+}
+
 func TestJSON(t *testing.T) {
 	testCases := []struct {
 		key      string
@@ -82,3 +224,97 @@ func TestJSON(t *testing.T) {
 		})
 	}
 }
+
+type customSource map[string]string
+
+func (customSource) Open() error  { return nil }
+func (customSource) Close() error { return nil }
+func (customSource) Kind() string { return "custom" }
+func (s customSource) Get(key string, dst Value) (bool, error) {
+	v, ok := s[key]
+	if !ok {
+		return false, nil
+	}
+
+	return true, dst.Set(v)
+}
+
+func TestKindExtractor(t *testing.T) {
+	sources := []Source{
+		EnvPrefix("TEST_"),
+		customSource{"foo": "bar"},
+		customSource{"foo": "baz", "qux": "quux"},
+	}
+
+	expect(t, KindOf(sources[0]), EnvKind)
+	expect(t, KindOf(sources[1]), "custom")
+	expect(t, KindOf(JSONVia("foo.json")), JSONKind)
+
+	var s string
+	ok, err := KindExtractor("custom", "foo").Get(sources, NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, s, "bar")
+
+	ok, err = KindExtractor("custom", "qux").Get(sources, NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, s, "quux")
+
+	ok, err = KindExtractor("other", "foo").Get(sources, NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, false)
+
+	ok, err = JSON("foo").Get(sources, NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, false)
+}
+
+type flatYAMLSource struct {
+	*FileSource
+}
+
+func parseFlatYAML(data []byte, dst interface{}) error {
+	values := make(map[string]interface{})
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 {
+			values[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	*dst.(*map[string]interface{}) = values
+	return nil
+}
+
+func TestConfig(t *testing.T) {
+	jsonSource := JSONVia("config.json").(*jsonSource)
+	if err := json.Unmarshal([]byte(`{"port": 8080}`), &jsonSource.Value); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	yamlSource := &flatYAMLSource{&FileSource{Parser: parseFlatYAML}}
+	if err := yamlSource.Parser([]byte("port: 9090\nhost: localhost"), &yamlSource.Value); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var port int
+	ok, err := Config("port").Get([]Source{EnvPrefix(""), jsonSource, yamlSource}, NewValue(&port))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, port, 8080)
+
+	ok, err = Config("port").Get([]Source{EnvPrefix(""), yamlSource, jsonSource}, NewValue(&port))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, port, 9090)
+
+	var host string
+	ok, err = Config("host").Get([]Source{jsonSource, yamlSource}, NewValue(&host))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, host, "localhost")
+
+	ok, err = Config("qux").Get([]Source{jsonSource, yamlSource}, NewValue(&host))
+	expect(t, err, nil)
+	expect(t, ok, false)
+}
@@ -0,0 +1,5 @@
+package flagga
+
+// fifoPrefix is the prefix of the values read from named pipes by the flags
+// with a FIFOTimeout.
+const fifoPrefix = "fifo:"
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package flagga
+
+import (
+	"fmt"
+	"time"
+)
+
+// readFIFO fails, as named pipes are not supported on this platform.
+func readFIFO(path string, timeout time.Duration) (string, error) {
+	return "", fmt.Errorf("reading values from fifos is not supported on this platform: %s", path)
+}
@@ -0,0 +1,137 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package flagga
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func mkfifo(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir(os.TempDir(), "flagga-fifo")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir: %s", err)
+	}
+
+	path := filepath.Join(dir, "fifo")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		os.RemoveAll(dir)
+		t.Skipf("unable to create fifo: %s", err)
+	}
+
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestFIFO(t *testing.T) {
+	path, cleanup := mkfifo(t)
+	defer cleanup()
+
+	go func() {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		f.WriteString("s3cr3t")
+	}()
+
+	var fs FlagSet
+	x := fs.String("x", "", "")
+	fs.Lookup("x").FIFOTimeout = time.Second
+
+	expect(t, fs.Parse([]string{"-x=fifo:" + path}), nil)
+	expect(t, *x, "s3cr3t")
+}
+
+func TestFIFOFromSource(t *testing.T) {
+	path, cleanup := mkfifo(t)
+	defer cleanup()
+
+	go func() {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		f.WriteString("42")
+	}()
+
+	os.Setenv("FIFO_TEST_X", "fifo:"+path)
+	defer os.Unsetenv("FIFO_TEST_X")
+
+	var fs FlagSet
+	x := fs.Int("x", 0, "", Env("FIFO_TEST_X"))
+	fs.Lookup("x").FIFOTimeout = time.Second
+
+	expect(t, fs.Parse(nil, EnvPrefix("")), nil)
+	expect(t, *x, 42)
+}
+
+func TestFIFOTimeout(t *testing.T) {
+	path, cleanup := mkfifo(t)
+	defer cleanup()
+
+	var fs FlagSet
+	fs.String("x", "", "")
+	fs.Lookup("x").FIFOTimeout = 50 * time.Millisecond
+
+	_, err := fs.parseNext([]string{"-x=fifo:" + path})
+	expect(t, err, fmt.Errorf("timeout reading value from fifo: %s", path))
+}
+
+func TestFIFOTimeoutWriterOpen(t *testing.T) {
+	path, cleanup := mkfifo(t)
+	defer cleanup()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		f.WriteString("partial")
+		<-done
+	}()
+
+	var fs FlagSet
+	fs.String("x", "", "")
+	fs.Lookup("x").FIFOTimeout = 50 * time.Millisecond
+
+	_, err := fs.parseNext([]string{"-x=fifo:" + path})
+	expect(t, err, fmt.Errorf("timeout reading value from fifo: %s", path))
+}
+
+func TestFIFONotPipe(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "flagga-fifo")
+	if err != nil {
+		t.Fatalf("unexpected error creating file: %s", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("s3cr3t")
+	f.Close()
+
+	fs := NewFlagSet("", "", ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	x := fs.String("x", "", "")
+	fs.Lookup("x").FIFOTimeout = time.Second
+
+	expect(t, fs.Parse([]string{"-x=fifo:" + f.Name()}), fmt.Errorf("not a fifo: %s", f.Name()))
+	expect(t, *x, "")
+}
+
+func TestFIFODisabled(t *testing.T) {
+	var fs FlagSet
+	x := fs.String("x", "", "")
+
+	expect(t, fs.Parse([]string{"-x=fifo:/does/not/exist"}), nil)
+	expect(t, *x, "fifo:/does/not/exist")
+}
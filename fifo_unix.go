@@ -0,0 +1,79 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package flagga
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"time"
+)
+
+// readFIFO reads all the content of the named pipe at the given path. It
+// fails if the file is not a named pipe or no writer opens the pipe and
+// closes it before the timeout.
+func readFIFO(path string, timeout time.Duration) (string, error) {
+	type result struct {
+		f   *os.File
+		err error
+	}
+
+	deadline := time.Now().Add(timeout)
+	timeoutErr := fmt.Errorf("timeout reading value from fifo: %s", path)
+
+	// opening the pipe blocks until there is a writer
+	ch := make(chan result, 1)
+	go func() {
+		f, err := os.Open(path)
+		ch <- result{f, err}
+	}()
+
+	var f *os.File
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return "", r.err
+		}
+		f = r.f
+	case <-time.After(timeout):
+		// open the pipe for writing so the pending open is unblocked, and
+		// close the pipe it opens
+		if w, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0); err == nil {
+			_ = w.Close()
+		}
+		go func() {
+			if r := <-ch; r.err == nil {
+				_ = r.f.Close()
+			}
+		}()
+		return "", timeoutErr
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		return "", fmt.Errorf("not a fifo: %s", path)
+	}
+
+	// pipes support deadlines, so a writer that never closes the pipe
+	// doesn't block the read forever
+	if err := f.SetReadDeadline(deadline); err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return "", timeoutErr
+	} else if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
@@ -1,12 +1,20 @@
 package flagga
 
 import (
+	"bytes"
+	"context"
+	"encoding"
 	"fmt"
 	"io"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // Flag is a single flag in the program.
@@ -16,21 +24,144 @@ type Flag struct {
 	Value      Value
 	Default    interface{}
 	Extractors []Extractor
+	// FIFOTimeout enables reading the value of the flag from a named pipe
+	// when it's given as "fifo:/path/to/pipe", waiting at most the given
+	// duration for a writer. If it's zero, values are never read from pipes.
+	// Named pipes are only supported on unix platforms.
+	FIFOTimeout time.Duration
+	// SourceOnly flags can only be filled using the sources. They are
+	// treated as unknown flags if they are given in the command line.
+	SourceOnly bool
+	// AllowGrouping allows the values of integer flags to group their digits
+	// using commas, such as 1,000,000. Digits can always be grouped using
+	// underscores, such as 1_000_000.
+	AllowGrouping bool
+	// ExpandEnv expands ${VAR} and $VAR references to environment variables
+	// in the string values found in the sources, such as ${HOME}/cache in a
+	// config file. Values given in the arguments are never expanded.
+	ExpandEnv bool
+	// TrimSpace trims the spaces and newlines around the string values found
+	// in the sources, such as the trailing newline of a secret mounted as a
+	// file. Values given in the arguments are never trimmed. It can be
+	// enabled for all the flags with SetTrimSpace.
+	TrimSpace bool
+	// DurationSeconds makes duration flags take the bare numbers found in
+	// the sources, such as "timeout": 30 in a config file, as seconds instead
+	// of nanoseconds. Values with a unit, such as "30s", are not affected.
+	DurationSeconds bool
+	// Negatable bool flags can also be given as -no-name to set them to
+	// false.
+	Negatable bool
+	// EmptyIsTrue makes bool flags true when a source has their key with an
+	// empty value, such as DEBUG= in the environment.
+	EmptyIsTrue bool
+	// LenientBool makes bool flags take yes, no, on and off, in any case, as
+	// values found in the sources, such as "verbose": "yes" in a config
+	// file. Values given in the arguments are still strict.
+	LenientBool bool
+	// MergeSources makes list flags take the values of all their extractors
+	// that match, concatenated in the order of the extractors. By default,
+	// the first extractor that matches provides all the values.
+	MergeSources bool
+	// AppendArgs makes list flags given in the arguments take the values
+	// found in the sources first, followed by the values given in the
+	// arguments, in the order they were given. By default, the values given
+	// in the arguments replace the ones in the sources.
+	AppendArgs bool
+	// Shorthand is an alternative name of the flag, usually a single letter,
+	// set with the constructors ending in P.
+	Shorthand string
+	// Required flags must be given in the arguments or found in the
+	// sources. Parsing fails if they are not.
+	Required bool
+	// MustBeUTF8 makes string flags reject values that are not valid UTF-8,
+	// both from the arguments and from the sources.
+	MustBeUTF8 bool
+	// ClearOnEmpty makes list flags given with an empty value in the
+	// arguments, such as -x= or -x "", drop all their values, including the
+	// default ones. Values given after it are appended as usual, so a
+	// non-empty default can be replaced with nothing.
+	ClearOnEmpty bool
+	// AllowExpressions makes numeric flags accept simple arithmetic
+	// expressions with the +, -, *, / and % operators and parentheses, such
+	// as 2*4 or 30*1000, both from the arguments and from the sources.
+	// Divisions between integers are integer divisions and integer flags
+	// reject expressions whose result is not an integer.
+	AllowExpressions bool
+	// Secret flags are read without echoing them when they are asked to the
+	// user because of SetInteractive, and their values are not written by
+	// WriteConfig unless a mask is set with SetSecretMask.
+	Secret bool
+	// SplitOn splits the string values found in the sources for list flags
+	// on any of the given separators, such as "," and "\n", trimming the
+	// spaces around the elements and dropping the empty ones. It can be used
+	// for lists in environment variables or mounted files. If it's empty,
+	// the whole string is a single element of the list.
+	SplitOn []string
 }
 
 // FlagSet is a collection of unique flags.
 type FlagSet struct {
-	name          string
-	description   string
-	parsed        bool
-	args          []string
-	nonFlags      []string
-	sources       []Source
-	flagOrder     []string
-	flags         map[string]*Flag
-	found         map[string]*Flag
-	out           io.Writer
-	errorHandling ErrorHandling
+	name           string
+	description    string
+	parsed         bool
+	args           []string
+	argTotal       int
+	argIdx         int
+	argIndexes     map[string]int
+	argPositions   []int
+	nonFlags       []string
+	unknown        []string
+	ignoreUnknown  bool
+	unknownHandler func(name, value string) error
+	requireParse   bool
+	messages       *Messages
+	parent         *FlagSet
+	commandOrder   []string
+	commands       map[string]*FlagSet
+	command        *FlagSet
+	commandArgs    []string
+	stopAtCommand  bool
+	commandName    string
+	requireCommand bool
+	allowAbbrev    bool
+	allowBundling  bool
+	noInlineValues bool
+	warnAdjacent   bool
+	trimSpace      bool
+	sourceTimeout  time.Duration
+	decoders       map[string]ParseFunc
+	encoders       map[string]EncodeFunc
+	auditLog       []AuditEntry
+	normalize      func(string) string
+	envPrefix      string
+	interactive    bool
+	secretMask     string
+	keyPrefix      string
+	allowRedefine  bool
+	helpSections   []helpSection
+	positionals    []positional
+	positionalArgs map[string]int
+	frozen         bool
+	shorthands     map[string]string
+	deprecated     map[string]string
+	warned         map[string]bool
+	resolveRefs    bool
+	shortCircuits  map[string]error
+	boolNextToken  bool
+	templates      map[string]string
+	allOrNone      [][]string
+	overrides      []string
+	validators     []func(*FlagSet) error
+	flagPositional map[string]int
+	configFlag     string
+	origins        map[string]Origin
+	sources        []Source
+	flagOrder      []string
+	flags          map[string]*Flag
+	found          map[string]*Flag
+	out            io.Writer
+	errorHandling  ErrorHandling
 
 	// Usage prints the usage instructions of the flag set.
 	Usage func()
@@ -71,41 +202,22 @@ func (fs *FlagSet) Init(name, description string, errorHandling ErrorHandling) {
 var exit = os.Exit
 
 // Parse fills the flags with values from the given arguments and sources.
+// A lone dash ("-") is always a positional argument and never a flag.
+// Scalar flags given more than once in the arguments keep the last value,
+// while list flags get all of them, in the order they were given. Flags given
+// in the arguments are not looked up in the sources, so the values of list
+// flags in the sources are replaced, unless they have the AppendArgs option.
 func (fs *FlagSet) Parse(args []string, sources ...Source) error {
+	return fs.ParseContext(context.Background(), args, sources...)
+}
+
+// ParseContext fills the flags with values from the given arguments and
+// sources like Parse, opening the sources implementing ContextSource with
+// the given context, so opening them can be cancelled.
+func (fs *FlagSet) ParseContext(ctx context.Context, args []string, sources ...Source) error {
 	if fs.parsed {
 		return nil
 	}
-	fs.parsed = true
-	fs.sources = sources
-
-	if fs.found == nil {
-		fs.found = make(map[string]*Flag)
-	}
-	for {
-		var err error
-		args, err = fs.parseNext(args)
-		if err != nil {
-			if err == ErrHelp {
-				fs.printUsage()
-			} else {
-				fs.printError(err)
-			}
-
-			switch fs.errorHandling {
-			case ContinueOnError:
-				return err
-			case PanicOnError:
-				panic(err)
-			case ExitOnError:
-				exit(2)
-				return nil
-			}
-		}
-
-		if len(args) == 0 {
-			break
-		}
-	}
 
 	defer func() {
 		for _, s := range sources {
@@ -113,293 +225,2103 @@ func (fs *FlagSet) Parse(args []string, sources ...Source) error {
 		}
 	}()
 
-	for _, s := range sources {
-		if err := s.Open(); err != nil {
-			return err
-		}
+	return fs.parse(ctx, args, sources, false)
+}
+
+// ParseGlobals fills the flags with values from the given arguments and
+// sources like Parse, but stops parsing the arguments at the first positional
+// argument, which is returned as the name of the subcommand along with the
+// arguments after it. It can be used for programs such as git, whose global
+// flags are given before the subcommand and the rest of the arguments are
+// handled by it. If there is no positional argument, the subcommand is empty.
+func (fs *FlagSet) ParseGlobals(
+	args []string,
+	sources ...Source,
+) (subcommand string, rest []string, err error) {
+	defer func(stop bool) { fs.stopAtCommand = stop }(fs.stopAtCommand)
+	fs.stopAtCommand = true
+	if err := fs.Parse(args, sources...); err != nil {
+		return "", nil, err
 	}
 
-	// now find the ones that are not filled using other sources
-	for name, f := range fs.flags {
-		if _, ok := fs.found[name]; !ok {
-			var found bool
-			for _, e := range f.Extractors {
-				if ok, err := e.Get(sources, f.Value); err != nil {
-					return err
-				} else if ok {
-					found = true
-					break
-				}
-			}
+	return fs.commandName, fs.commandArgs, nil
+}
 
-			// if no value could be found, just use the default value
-			if !found {
-				fs.found[name] = f
-				if err := f.Value.Set(f.Default); err != nil {
-					return err
-				}
-			}
+// ParseWithProgram fills the flags with values from the given arguments and
+// sources like Parse, but takes the first argument as the program name, as in
+// os.Args, instead of parsing it. The program name becomes the name of the
+// flag set if it has none.
+func (fs *FlagSet) ParseWithProgram(args []string, sources ...Source) error {
+	if len(args) > 0 {
+		if fs.name == "" {
+			fs.name = filepath.Base(args[0])
 		}
+		args = args[1:]
 	}
 
-	return nil
+	return fs.Parse(args, sources...)
 }
 
-func (fs *FlagSet) printUsage() {
-	if fs.Usage == nil {
-		fs.usage()
-	} else {
-		fs.Usage()
-	}
-}
+// Origin is where the value of a flag comes from.
+type Origin byte
 
-func (fs *FlagSet) printError(err error) {
-	fmt.Fprint(fs.Output(), err)
-	fs.printUsage()
+const (
+	// OriginArgs is the origin of the flags given in the arguments.
+	OriginArgs Origin = iota + 1
+	// OriginSource is the origin of the flags extracted from the sources.
+	OriginSource
+	// OriginDefault is the origin of the flags with their default value.
+	OriginDefault
+)
+
+// AuditEntry is an assignment of a value to a flag recorded in the audit log
+// of a flag set.
+type AuditEntry struct {
+	// Flag is the name of the flag.
+	Flag string
+	// Value is the value of the flag after the assignment. For list flags
+	// given more than once in the arguments, it has all the values given so
+	// far.
+	Value interface{}
+	// Origin is where the value comes from.
+	Origin Origin
+	// Time is when the value was assigned.
+	Time time.Time
 }
 
-// ErrHelp is returned when -h, --h, --help or -help are found.
-var ErrHelp = fmt.Errorf("flagga: help requested")
+// AuditLog returns all the assignments of values to the flags made while
+// parsing the flag set, in the order they were made, so it can be known what
+// set each flag and when. Flags given more than once in the arguments have an
+// entry for each time, and the flags not given anywhere have an entry for
+// their default value.
+func (fs *FlagSet) AuditLog() []AuditEntry {
+	var log = make([]AuditEntry, len(fs.auditLog))
+	copy(log, fs.auditLog)
+	return log
+}
 
-func (fs *FlagSet) usage() {
-	if fs.name == "" {
-		fmt.Fprint(fs.Output(), "Usage:\n")
-	} else {
-		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.name)
+// audit records the current value of the given flag in the audit log.
+func (fs *FlagSet) audit(f *Flag, o Origin) {
+	var v interface{}
+	if g, ok := f.Value.(Getter); ok {
+		v = snapshot(g.Get())
 	}
+	fs.auditLog = append(fs.auditLog, AuditEntry{f.Name, v, o, time.Now()})
+}
 
-	if fs.description != "" {
-		fmt.Fprintf(
-			fs.Output(), "\n  %s\n",
-			strings.Replace(fs.description, "\n", "\n  ", -1),
-		)
+// snapshot returns a copy of the given value if it's a slice or a map, so it
+// doesn't change when more values are added to the flag.
+func snapshot(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Slice && !rv.IsNil():
+		cp := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		reflect.Copy(cp, rv)
+		return cp.Interface()
+	case rv.Kind() == reflect.Map && !rv.IsNil():
+		cp := reflect.MakeMapWithSize(rv.Type(), rv.Len())
+		for _, k := range rv.MapKeys() {
+			cp.SetMapIndex(k, rv.MapIndex(k))
+		}
+		return cp.Interface()
+	default:
+		return v
 	}
-
-	fmt.Fprint(fs.Output(), "\n")
-	fs.PrintDefaults()
 }
 
-// PrintDefaults prints all flags with their description and default value.
-func (fs *FlagSet) PrintDefaults() {
-	for _, name := range fs.flagOrder {
-		f := fs.flags[name]
-		typ := strings.Replace(
-			reflect.TypeOf(f.Default).String(),
-			"[]", "list of ", 1,
-		)
-		fmt.Fprintf(fs.Output(), "  -%s %s\n", name, typ)
+// ParseResult describes the outcome of parsing a flag set.
+type ParseResult struct {
+	// Args are the positional arguments.
+	Args []string
+	// Found are the names of the flags given in the arguments or extracted
+	// from the sources, in the order they were defined.
+	Found []string
+	// UnknownArgs are the unknown flags and their values, which are only
+	// collected if SetIgnoreUnknown is enabled.
+	UnknownArgs []string
+	// Origins are the origins of the values of all the flags, keyed by
+	// flag name.
+	Origins map[string]Origin
+}
 
-		fmt.Fprint(fs.Output(), "  \t")
-		if f.Usage != "" {
-			fmt.Fprint(
-				fs.Output(),
-				strings.Replace(f.Usage, "\n", "\n  \t", -1),
-			)
-		}
+// ParseResult fills the flags with values from the given arguments and
+// sources like Parse, and returns a description of the outcome, so callers
+// don't need to query it with several methods.
+func (fs *FlagSet) ParseResult(args []string, sources ...Source) (*ParseResult, error) {
+	if err := fs.Parse(args, sources...); err != nil {
+		return nil, err
+	}
 
-		s, ok := f.Default.(string)
-		if !ok || s != "" {
-			fmt.Fprintf(fs.Output(), " (default value: %s)\n", prettyValue(f.Default))
-		} else {
-			fmt.Fprint(fs.Output(), "\n")
-		}
+	var result = ParseResult{
+		Args:        fs.Args(),
+		UnknownArgs: fs.UnknownArgs(),
+		Origins:     make(map[string]Origin, len(fs.origins)),
 	}
-}
 
-func prettyValue(v interface{}) string {
-	switch v := v.(type) {
-	case []string:
-		return fmt.Sprintf("[%s]", strings.Join(v, ", "))
-	case []int:
-		var parts = make([]string, len(v))
-		for i, val := range v {
-			parts[i] = fmt.Sprint(val)
-		}
-		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
-	case []uint:
-		var parts = make([]string, len(v))
-		for i, val := range v {
-			parts[i] = fmt.Sprint(val)
-		}
-		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
-	case []int64:
-		var parts = make([]string, len(v))
-		for i, val := range v {
-			parts[i] = fmt.Sprint(val)
-		}
-		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
-	case []uint64:
-		var parts = make([]string, len(v))
-		for i, val := range v {
-			parts[i] = fmt.Sprint(val)
-		}
-		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
-	case []float64:
-		var parts = make([]string, len(v))
-		for i, val := range v {
-			parts[i] = fmt.Sprint(val)
+	for _, name := range fs.flagOrder {
+		o, ok := fs.origins[name]
+		if !ok {
+			continue
 		}
-		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
-	case []time.Duration:
-		var parts = make([]string, len(v))
-		for i, val := range v {
-			parts[i] = fmt.Sprint(val)
+
+		result.Origins[name] = o
+		if o != OriginDefault {
+			result.Found = append(result.Found, name)
 		}
-		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
-	default:
-		return fmt.Sprint(v)
 	}
+
+	return &result, nil
 }
 
-func (fs *FlagSet) parseNext(args []string) ([]string, error) {
+// parse fills the flags of the flag set and the ones of the chosen
+// subcommand, if any. Sources are opened after parsing the arguments unless
+// they were already opened.
+func (fs *FlagSet) parse(ctx context.Context, args []string, sources []Source, opened bool) error {
+	fs.parsed = true
+	fs.sources = sources
+
+	if fs.found == nil {
+		fs.found = make(map[string]*Flag)
+	}
+	fs.argTotal = len(args)
 	for {
-		if len(args) == 0 {
-			return nil, nil
+		var err error
+		args, err = fs.parseNext(args)
+		if err != nil {
+			return fs.handleError(err)
 		}
 
-		arg := args[0]
-		args = args[1:]
-		if len(arg) < 2 || arg[0] != '-' {
-			fs.args = append(fs.args, arg)
-			// this was not a flag, skip it
-			continue
+		if len(args) == 0 {
+			break
 		}
+	}
+	fs.argIdx = -1
 
-		var name string
-		if arg == "--" {
-			// -- terminates flags
-			fs.args = append(fs.args, args...)
-			return nil, nil
-		} else if strings.HasPrefix(arg, "--") {
-			name = arg[2:]
-		} else {
-			name = arg[1:]
+	for _, name := range fs.flagOrder {
+		if err, ok := fs.shortCircuits[name]; ok && fs.found[name] != nil {
+			return err
 		}
+	}
 
-		if len(name) == 0 || name[0] == '-' || name[0] == '=' {
-			return nil, fmt.Errorf("invalid flag syntax: %s", arg)
+	if fs.requireCommand && fs.command == nil {
+		return fs.handleError(fmt.Errorf("%s", fs.msgs().MissingSubCommand))
+	}
+
+	if err := fs.bindPositionals(); err != nil {
+		return fs.handleError(err)
+	}
+
+	if err := fs.bindFlagPositionals(); err != nil {
+		return fs.handleError(err)
+	}
+
+	if !opened {
+		if err := fs.openSources(ctx, sources); err != nil {
+			return err
 		}
+	}
 
-		if name == "h" || name == "help" {
-			return nil, ErrHelp
+	if fs.configFlag != "" {
+		configs, err := fs.configSources()
+		if err != nil {
+			return fs.handleError(err)
 		}
 
-		idx := strings.IndexRune(name, '=')
-		if idx > 0 {
-			// has a value
-			name, value := name[:idx], name[idx+1:]
-			if len(value) == 0 {
-				return nil, fmt.Errorf("invalid flag syntax: %s", arg)
+		defer func() {
+			for _, s := range configs {
+				_ = s.Close()
 			}
+		}()
 
-			if err := fs.setValue(name, value); err != nil {
-				return nil, err
+		if err := fs.openSources(ctx, configs); err != nil {
+			return err
+		}
+
+		sources = append(sources[:len(sources):len(sources)], configs...)
+	}
+
+	// now find the ones that are not filled using other sources, in the
+	// order they were defined
+	var missing, templated []string
+	for _, name := range fs.flagOrder {
+		f := fs.flags[name]
+		if _, ok := fs.found[name]; ok && f.AppendArgs && isSlice(f.Value) {
+			if err := fs.prependSources(sources, f); err != nil {
+				return err
 			}
-		} else {
-			f, ok := fs.flags[name]
-			if ok && isBool(f.Value) {
-				fs.found[name] = f
-				if err := f.Value.Set(true); err != nil {
-					return nil, err
+		} else if !ok {
+			var found bool
+			if f.MergeSources && isSlice(f.Value) {
+				var err error
+				if found, err = fs.mergeSources(sources, f); err != nil {
+					return err
+				}
+			} else {
+				for _, e := range f.Extractors {
+					if ok, err := e.Get(sources, sourceValue{fs, f}); err != nil {
+						return err
+					} else if ok {
+						found = true
+						break
+					}
 				}
-
-				return args, nil
 			}
 
-			if !ok {
-				return nil, fmt.Errorf("unknown flag %s", name)
+			if !found {
+				var err error
+				if found, err = stateValue(sources, name, sourceValue{fs, f}); err != nil {
+					return err
+				}
 			}
 
-			if len(args) == 0 {
-				return nil, fmt.Errorf("expecting value for flag: %s", name)
+			if found {
+				fs.setOrigin(name, OriginSource)
+				fs.audit(f, OriginSource)
 			}
 
-			arg, args = args[0], args[1:]
-			if strings.HasPrefix(arg, "-") {
-				return nil, fmt.Errorf("expecting value for flag: %s", name)
-			}
+			// if no value could be found, just use the default value
+			if !found {
+				if f.Required {
+					missing = append(missing, name)
+				}
 
-			if err := fs.setValue(name, arg); err != nil {
-				return nil, err
+				if _, ok := fs.templates[name]; ok {
+					templated = append(templated, name)
+				}
+
+				fs.found[name] = f
+				fs.setOrigin(name, OriginDefault)
+				if !holdsDefault(f) {
+					if err := f.Value.Set(f.Default); err != nil {
+						return err
+					}
+				}
+				fs.audit(f, OriginDefault)
 			}
 		}
+	}
 
-		return args, nil
+	sort.Slice(missing, func(i, j int) bool {
+		return fs.flagIndex(missing[i]) < fs.flagIndex(missing[j])
+	})
+
+	missing, err := fs.prompt(missing)
+	if err != nil {
+		return err
 	}
-}
 
-func (fs *FlagSet) setValue(name, value string) error {
-	f, alreadyFound := fs.found[name]
-	if alreadyFound && !isSlice(f.Value) {
-		// ignore, we already have a value for this flag
-		return nil
+	if len(missing) > 0 {
+		err := fmt.Errorf(fs.msgs().MissingRequired, strings.Join(missing, ", "))
+		return fs.handleError(err)
 	}
 
-	if alreadyFound {
-		if err := f.Value.Set(value); err != nil {
-			return err
+	for _, names := range fs.allOrNone {
+		var given int
+		for _, name := range names {
+			if o := fs.origins[name]; o == OriginArgs || o == OriginSource {
+				given++
+			}
 		}
-	} else {
-		f, ok := fs.flags[name]
-		if !ok {
-			return fmt.Errorf("unknown flag %s", name)
+
+		if given > 0 && given < len(names) {
+			err := fmt.Errorf(fs.msgs().AllOrNone, strings.Join(names, ", "))
+			return fs.handleError(err)
+		}
+	}
+
+	for _, name := range fs.overrides {
+		if fs.isDefault(fs.flags[name]) {
+			err := fmt.Errorf(fs.msgs().NotOverridden, name, prettyValue(fs.flags[name].Default))
+			return fs.handleError(err)
+		}
+	}
+
+	if err := fs.renderTemplates(templated); err != nil {
+		return fs.handleError(err)
+	}
+
+	if fs.resolveRefs {
+		if err := fs.resolveReferences(); err != nil {
+			return fs.handleError(err)
 		}
+	}
 
-		if fs.found == nil {
-			fs.found = make(map[string]*Flag)
+	for _, validate := range fs.validators {
+		if err := validate(fs); err != nil {
+			return fs.handleError(err)
 		}
+	}
+
+	if fs.command != nil {
+		return fs.command.parse(ctx, fs.commandArgs, sources, true)
+	}
+
+	return nil
+}
+
+// prependSources puts the values found in the sources for the given list
+// flag before the values it already has from the arguments.
+func (fs *FlagSet) prependSources(sources []Source, f *Flag) error {
+	dst := reflect.New(sliceType(f))
+	tmp := *f
+	tmp.Value = NewValue(dst.Interface())
 
-		fs.found[name] = f
-		if err := f.Value.Set(value); err != nil {
+	var found bool
+	if f.MergeSources {
+		var err error
+		if found, err = fs.mergeSources(sources, &tmp); err != nil {
 			return err
 		}
+	} else {
+		for _, e := range f.Extractors {
+			ok, err := e.Get(sources, sourceValue{fs, &tmp})
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				found = true
+				break
+			}
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	args := reflect.ValueOf(f.Value.(Getter).Get())
+	if err := fs.setFlag(f, reflect.AppendSlice(dst.Elem(), args).Interface()); err != nil {
+		return err
 	}
 
+	fs.audit(f, OriginSource)
 	return nil
 }
 
-// Parsed returns whether the flag set has already been parsed.
-func (fs *FlagSet) Parsed() bool {
-	return fs.parsed
+// sliceType returns the type of the slice held by the given list flag.
+func sliceType(f *Flag) reflect.Type {
+	return reflect.TypeOf(f.Value.(Getter).Get())
 }
 
-// NFlags returns the number of flags that have been filled.
-func (fs *FlagSet) NFlags() int { return len(fs.found) }
+// openSources opens the given sources in order. The ones implementing
+// ContextSource are opened with the given context, limited by the timeout
+// set with SetSourceTimeout, if any.
+func (fs *FlagSet) openSources(ctx context.Context, sources []Source) error {
+	for _, s := range sources {
+		if ds, ok := s.(decoderSource); ok {
+			ds.useDecoders(fs.decoders)
+		}
 
-// NArg returns the number of arguments that have been found.
-func (fs *FlagSet) NArg() int { return len(fs.args) }
+		cs, ok := s.(ContextSource)
+		if !ok {
+			if err := s.Open(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := fs.openContext(ctx, cs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fs *FlagSet) openContext(ctx context.Context, s ContextSource) error {
+	if fs.sourceTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, fs.sourceTimeout)
+		defer cancel()
+	}
+
+	err := s.OpenContext(ctx)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return ErrSourceTimeout
+	}
+	return err
+}
+
+// SetSourceTimeout sets the maximum time opening each of the sources
+// implementing ContextSource can take when the flag set is parsed. Parsing
+// fails with ErrSourceTimeout if any of them takes longer. Sources that
+// don't implement ContextSource are opened without a timeout. If it's zero,
+// which is the default, there is no timeout.
+func (fs *FlagSet) SetSourceTimeout(d time.Duration) { fs.sourceTimeout = d }
+
+// SetDecoder sets the function used to decode the config files of the given
+// kind, such as yaml.Unmarshal of gopkg.in/yaml.v2 for YAMLKind, by the
+// sources created without a decoder, like the ones FileVia and ConfigFlag
+// create for .yaml and .yml files.
+func (fs *FlagSet) SetDecoder(kind string, unmarshal ParseFunc) {
+	if fs.decoders == nil {
+		fs.decoders = make(map[string]ParseFunc)
+	}
+	fs.decoders[kind] = unmarshal
+}
+
+// mergeSources fills the given list flag with the values of all its
+// extractors that match, concatenated in order.
+func (fs *FlagSet) mergeSources(sources []Source, f *Flag) (bool, error) {
+	typ := sliceType(f)
+	merged := reflect.MakeSlice(typ, 0, 0)
+	var found bool
+	for _, e := range f.Extractors {
+		dst := reflect.New(typ)
+		tmp := *f
+		tmp.Value = NewValue(dst.Interface())
+		ok, err := e.Get(sources, sourceValue{fs, &tmp})
+		if err != nil {
+			return false, err
+		}
+
+		if ok {
+			found = true
+			merged = reflect.AppendSlice(merged, dst.Elem())
+		}
+	}
+
+	if !found {
+		return false, nil
+	}
+
+	return true, fs.setFlag(f, merged.Interface())
+}
+
+// handleError reports an error found while parsing and acts according to the
+// error handling policy of the flag set.
+func (fs *FlagSet) handleError(err error) error {
+	if err == ErrHelp {
+		fs.printUsage()
+	} else {
+		fs.printError(err)
+	}
+
+	switch fs.errorHandling {
+	case PanicOnError:
+		panic(err)
+	case ExitOnError:
+		exit(2)
+		return nil
+	}
+
+	return err
+}
+
+// ParseLayered fills the flags with values from the given arguments, the
+// environment variables and the given config file, in that order of
+// priority. The format of the config file is chosen by its extension and no
+// file is used if it's empty.
+// Flags without extractors will get an Env extractor for the name returned by
+// EnvName and an extractor for their name in the config file.
+func (fs *FlagSet) ParseLayered(args []string, configFile string) error {
+	var sources = []Source{EnvPrefix("")}
+	var file Source
+	if configFile != "" {
+		var err error
+		file, err = FileVia(configFile)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, file)
+	}
+
+	for _, name := range fs.flagOrder {
+		f := fs.flags[name]
+		if len(f.Extractors) > 0 {
+			continue
+		}
+
+		f.Extractors = []Extractor{Env(fs.EnvName(name))}
+		if file != nil {
+			f.Extractors = append(f.Extractors, KindExtractor(KindOf(file), name))
+		}
+	}
+
+	return fs.Parse(args, sources...)
+}
+
+// ParseInto fills the given map with values from the given arguments and
+// sources. Every key of the map that is not a defined flag defines a flag
+// whose type and default value are the ones of its value in the map, with a
+// Config extractor for its name. Once parsed, the values of the map are
+// replaced by the values of their flags.
+func (fs *FlagSet) ParseInto(
+	m map[string]interface{},
+	args []string,
+	sources ...Source,
+) error {
+	var names = make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := fs.flags[fs.normalizeName(name)]; ok {
+			continue
+		}
+
+		def := m[name]
+		if !isSupported(def) {
+			return fmt.Errorf("unsupported type %T for flag %s", def, name)
+		}
+
+		v := reflect.New(reflect.TypeOf(def)).Interface()
+		fs.addFlag(name, def, "", NewValue(v), []Extractor{Config(name)})
+	}
+
+	if err := fs.Parse(args, sources...); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if g, ok := fs.flags[fs.normalizeName(name)].Value.(Getter); ok {
+			m[name] = g.Get()
+		}
+	}
+
+	return nil
+}
+
+// SetEnvPrefix sets the prefix of the names of the environment variables
+// derived from the names of the flags by EnvName, such as APP_.
+func (fs *FlagSet) SetEnvPrefix(prefix string) { fs.envPrefix = prefix }
+
+// EnvName returns the name of the environment variable derived from the name
+// of the given flag, which is used by AutoEnv and ParseLayered. The name is in
+// upper case with dashes and dots replaced by underscores, and the prefix set
+// with SetEnvPrefix before it, so log-level is APP_LOG_LEVEL with the APP_
+// prefix. Subcommands without their own prefix use the prefix of their parent
+// followed by their name, so the flags of the migrate subcommand of the db
+// subcommand are TOOL_DB_MIGRATE_* with the TOOL_ prefix in the root flag set.
+func (fs *FlagSet) EnvName(flagName string) string {
+	return fs.fullEnvPrefix() + envCase(fs.normalizeName(flagName))
+}
+
+// fullEnvPrefix returns the prefix of the environment variables of the flag
+// set, including the names of the subcommands up to the closest flag set with
+// a prefix set.
+func (fs *FlagSet) fullEnvPrefix() string {
+	if fs.envPrefix != "" || fs.parent == nil {
+		return fs.envPrefix
+	}
+	return fs.parent.fullEnvPrefix() + envCase(fs.name) + "_"
+}
+
+func envCase(name string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// AutoEnv adds an Env extractor for the name returned by EnvName to all the
+// defined flags without one, so any flag can be given in the environment as
+// long as an environment source is given to Parse, such as EnvPrefix("").
+// The environment variables are also listed in the generated documentation.
+func (fs *FlagSet) AutoEnv() {
+	for _, name := range fs.flagOrder {
+		f := fs.flags[name]
+		if !hasEnvExtractor(f) {
+			f.Extractors = append(f.Extractors, Env(fs.EnvName(name)))
+		}
+	}
+}
+
+func hasEnvExtractor(f *Flag) bool {
+	for _, e := range f.Extractors {
+		switch e := e.(type) {
+		case kindExtractor:
+			if e.kind == EnvKind {
+				return true
+			}
+		case prefixedEnvExtractor:
+			return true
+		}
+	}
+	return false
+}
+
+func (fs *FlagSet) printUsage() {
+	if fs.Usage == nil {
+		fs.usage()
+	} else {
+		fs.Usage()
+	}
+}
+
+func (fs *FlagSet) printError(err error) {
+	fmt.Fprint(fs.Output(), err)
+	fs.printUsage()
+}
+
+// ErrHelp is returned when -h, --h, --help or -help are found.
+var ErrHelp = fmt.Errorf("flagga: help requested")
+
+func (fs *FlagSet) usage() {
+	if fs.name == "" {
+		fmt.Fprintln(fs.Output(), fs.msgs().Usage)
+	} else {
+		fmt.Fprintf(fs.Output(), fs.msgs().UsageOf+"\n", fs.name)
+	}
+
+	if fs.description != "" {
+		fmt.Fprintf(
+			fs.Output(), "\n  %s\n",
+			strings.Replace(fs.description, "\n", "\n  ", -1),
+		)
+	}
+
+	fmt.Fprint(fs.Output(), "\n")
+	fs.PrintDefaults()
+
+	if len(fs.commandOrder) > 0 {
+		fmt.Fprintf(fs.Output(), "\n%s\n", fs.msgs().SubCommands)
+		for _, name := range fs.commandOrder {
+			fmt.Fprintf(fs.Output(), "  %s\n", name)
+			if desc := fs.commands[name].description; desc != "" {
+				fmt.Fprintf(
+					fs.Output(), "  \t%s\n",
+					strings.Replace(desc, "\n", "\n  \t", -1),
+				)
+			}
+		}
+	}
+
+	for _, section := range fs.helpSections {
+		fmt.Fprintf(
+			fs.Output(), "\n%s:\n  %s\n",
+			section.title,
+			strings.Replace(section.body, "\n", "\n  ", -1),
+		)
+	}
+}
+
+type helpSection struct {
+	title string
+	body  string
+}
+
+// AddHelpSection adds a section with the given title and body to the usage
+// of the flag set, such as examples, environment variables or exit codes.
+// Sections are printed in the order they were added after the flags and
+// subcommands.
+func (fs *FlagSet) AddHelpSection(title, body string) {
+	fs.helpSections = append(fs.helpSections, helpSection{title, body})
+}
+
+// PrintDefaults prints all flags with their description and default value,
+// except the source-only flags, which can't be given in the command line.
+func (fs *FlagSet) PrintDefaults() {
+	for _, name := range fs.flagOrder {
+		if f := fs.flags[name]; !f.SourceOnly {
+			fs.printFlag(fs.Output(), f)
+		}
+	}
+}
+
+// FlagUsage returns the usage of the flag with the given name as printed by
+// PrintDefaults, so it can be shown on its own, for example when the flag has
+// an invalid value. It returns an empty string if the flag is not defined.
+func (fs *FlagSet) FlagUsage(name string) string {
+	f := fs.Lookup(name)
+	if f == nil {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	fs.printFlag(&buf, f)
+	return buf.String()
+}
+
+// printFlag prints the given flag with its description and default value.
+func (fs *FlagSet) printFlag(w io.Writer, f *Flag) {
+	fmt.Fprintf(w, "  %s %s\n", usageName(f), fs.typeName(f))
+
+	fmt.Fprint(w, "  \t")
+	if f.Usage != "" {
+		fmt.Fprint(w, strings.Replace(f.Usage, "\n", "\n  \t", -1))
+	}
+
+	if fs.showsDefault(f) {
+		fmt.Fprintf(w, " "+fs.msgs().DefaultValue+"\n", prettyValue(f.Default))
+	} else {
+		fmt.Fprint(w, "\n")
+	}
+}
+
+// showsDefault reports whether the default value of the given flag is shown
+// in its usage. Empty strings are not shown, and neither are templates, as
+// their value is only known once they are rendered.
+func (fs *FlagSet) showsDefault(f *Flag) bool {
+	if _, ok := fs.templates[f.Name]; ok {
+		return false
+	}
+
+	s, ok := f.Default.(string)
+	return !ok || s != ""
+}
+
+// GetoptUsage returns a compact synopsis of the flag set in the style of the
+// programs using getopt, such as "usage: tool [-v] [-o file] --name NAME
+// src... dst". Names of a single character are given with a single dash and
+// the rest with two. Optional flags are enclosed in brackets, lists are
+// followed by an ellipsis and the name of the value of the flags is the one
+// quoted with backquotes in their usage or their name in upper case.
+// Positional arguments and subcommands are listed after the flags.
+func (fs *FlagSet) GetoptUsage() string {
+	var parts = []string{"usage:"}
+	if fs.name != "" {
+		parts = append(parts, fs.name)
+	}
+
+	for _, name := range fs.flagOrder {
+		f := fs.flags[name]
+		if f.SourceOnly {
+			continue
+		}
+
+		part := getoptName(f.Name)
+		if f.Shorthand != "" {
+			part = getoptName(f.Shorthand) + "|" + part
+		}
+
+		if !isBool(f.Value) {
+			part += " " + getoptValueName(f)
+		}
+
+		if !f.Required {
+			part = "[" + part + "]"
+		}
+
+		if isSlice(f.Value) {
+			part += "..."
+		}
+		parts = append(parts, part)
+	}
+
+	for _, p := range fs.positionals {
+		switch {
+		case p.variadic == nil:
+			parts = append(parts, p.name)
+		case p.min == 0:
+			parts = append(parts, "["+p.name+"...]")
+		default:
+			parts = append(parts, p.name+"...")
+		}
+	}
+
+	if len(fs.commandOrder) > 0 {
+		if fs.requireCommand {
+			parts = append(parts, "command")
+		} else {
+			parts = append(parts, "[command]")
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func getoptName(name string) string {
+	if utf8.RuneCountInString(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+// getoptValueName returns the name of the value of the given flag for its
+// getopt synopsis.
+func getoptValueName(f *Flag) string {
+	if start := strings.IndexByte(f.Usage, '`'); start >= 0 {
+		if end := strings.IndexByte(f.Usage[start+1:], '`'); end > 0 {
+			return f.Usage[start+1 : start+1+end]
+		}
+	}
+	return strings.ToUpper(strings.Replace(f.Name, "-", "_", -1))
+}
+
+// usageName returns the name of the given flag for its usage, including its
+// shorthand and the negated form of negatable flags. Flags with a shorthand
+// are rendered as -n, --name, as in getopt.
+func usageName(f *Flag) string {
+	name := f.Name
+	if f.Negatable && isBool(f.Value) {
+		name = "[no-]" + f.Name
+	}
+
+	if f.Shorthand != "" {
+		return "-" + f.Shorthand + ", --" + name
+	}
+	return "-" + name
+}
+
+// typeName returns the name of the type of the given flag for its usage.
+func (fs *FlagSet) typeName(f *Flag) string {
+	typ := reflect.TypeOf(f.Default).String()
+	if strings.HasPrefix(typ, "[]") {
+		typ = fmt.Sprintf(fs.msgs().ListOf, typ[2:])
+	}
+	return typ
+}
+
+func prettyValue(v interface{}) string {
+	switch v := v.(type) {
+	case []string:
+		return fmt.Sprintf("[%s]", strings.Join(v, ", "))
+	case []int:
+		var parts = make([]string, len(v))
+		for i, val := range v {
+			parts[i] = fmt.Sprint(val)
+		}
+		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
+	case []uint:
+		var parts = make([]string, len(v))
+		for i, val := range v {
+			parts[i] = fmt.Sprint(val)
+		}
+		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
+	case []int64:
+		var parts = make([]string, len(v))
+		for i, val := range v {
+			parts[i] = fmt.Sprint(val)
+		}
+		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
+	case []uint64:
+		var parts = make([]string, len(v))
+		for i, val := range v {
+			parts[i] = fmt.Sprint(val)
+		}
+		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
+	case []float64:
+		var parts = make([]string, len(v))
+		for i, val := range v {
+			parts[i] = fmt.Sprint(val)
+		}
+		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
+	case []time.Duration:
+		var parts = make([]string, len(v))
+		for i, val := range v {
+			parts[i] = fmt.Sprint(val)
+		}
+		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
+	case []net.IP:
+		var parts = make([]string, len(v))
+		for i, val := range v {
+			parts[i] = val.String()
+		}
+		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case url.URL:
+		return v.String()
+	case []url.URL:
+		var parts = make([]string, len(v))
+		for i, val := range v {
+			parts[i] = val.String()
+		}
+		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (fs *FlagSet) parseNext(args []string) ([]string, error) {
+	for {
+		if len(args) == 0 {
+			return nil, nil
+		}
+
+		// the index of the argument in the ones given to the flag set
+		fs.argIdx = fs.argTotal - len(args)
+		arg := args[0]
+		args = args[1:]
+		// a lone dash, commonly used to mean the standard input, is always a
+		// positional argument and never a flag
+		if arg == "-" || len(arg) == 0 || arg[0] != '-' {
+			if fs.stopAtCommand {
+				fs.commandName, fs.commandArgs = arg, args
+				return nil, nil
+			}
+
+			// the first positional argument chooses the subcommand
+			if len(fs.commands) > 0 && len(fs.args) == 0 {
+				cmd, ok, err := fs.lookupCommand(arg)
+				if err != nil {
+					return nil, err
+				}
+
+				if ok {
+					fs.command = cmd
+					fs.commandArgs = args
+					return nil, nil
+				}
+
+				if fs.requireCommand {
+					return nil, fmt.Errorf(fs.msgs().UnknownSubCommand, arg)
+				}
+			}
+
+			if fs.args == nil {
+				fs.args = make([]string, 0, fs.argTotal)
+				fs.argPositions = make([]int, 0, fs.argTotal)
+			}
+			fs.args = append(fs.args, arg)
+			fs.argPositions = append(fs.argPositions, fs.argIdx)
+			// this was not a flag, skip it
+			continue
+		}
+
+		var name string
+		if arg == "--" {
+			if fs.stopAtCommand && len(args) > 0 {
+				fs.commandName, fs.commandArgs = args[0], args[1:]
+				return nil, nil
+			}
+
+			// -- terminates flags
+			fs.args = append(fs.args, args...)
+			for i := range args {
+				fs.argPositions = append(fs.argPositions, fs.argIdx+1+i)
+			}
+			return nil, nil
+		} else if strings.HasPrefix(arg, "--") {
+			name = arg[2:]
+		} else {
+			name = arg[1:]
+		}
+
+		if len(name) == 0 || name[0] == '-' || name[0] == '=' {
+			return nil, fmt.Errorf(fs.msgs().InvalidSyntax, arg)
+		}
+
+		if name == "h" || name == "help" {
+			return nil, ErrHelp
+		}
+
+		// split the inline value only once to avoid building new names
+		var value string
+		idx := strings.IndexRune(name, '=')
+		hasValue := idx > 0
+		if hasValue {
+			if fs.noInlineValues {
+				return nil, fmt.Errorf(fs.msgs().InvalidSyntax, arg)
+			}
+			name, value = name[:idx], name[idx+1:]
+		}
+
+		name = fs.normalizeName(name)
+		if f, ok := fs.negatedFlag(name); ok && !hasValue {
+			name, value, hasValue = f.Name, "false", true
+		} else {
+			name = fs.longName(fs.undeprecatedName(name))
+		}
+
+		f, ok := fs.argFlag(name)
+		if !ok && fs.allowBundling && !strings.HasPrefix(arg, "--") {
+			rest, isBundle, err := fs.parseBundle(arg[1:], args)
+			if err != nil {
+				return nil, err
+			}
+
+			if isBundle {
+				return rest, nil
+			}
+		}
+
+		if !ok && fs.unknownHandler != nil {
+			// the next argument is taken as the value of the unknown flag
+			// if it doesn't look like a flag
+			if !hasValue && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+				value, args = args[0], args[1:]
+			}
+
+			if err := fs.unknownHandler(name, value); err != nil {
+				return nil, err
+			}
+			return args, nil
+		}
+
+		if !ok && fs.ignoreUnknown {
+			fs.unknown = append(fs.unknown, arg)
+			// the next argument is taken as the value of the unknown flag
+			// if it doesn't look like a flag
+			if !hasValue && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+				fs.unknown = append(fs.unknown, args[0])
+				args = args[1:]
+			}
+			return args, nil
+		}
+
+		if hasValue {
+			// the value is everything after the first "=", so values can
+			// contain "=" too. An empty value is only valid for string
+			// flags, as it can't be parsed as any other type
+			if len(value) == 0 && (!ok || !isString(f.Value) && !clearsOnEmpty(f)) {
+				return nil, fmt.Errorf(fs.msgs().InvalidSyntax, arg)
+			}
+
+			if !ok {
+				return nil, fmt.Errorf(fs.msgs().UnknownFlag, name)
+			}
+
+			if err := fs.setValue(f, value); err != nil {
+				return nil, err
+			}
+
+			if fs.warnAdjacent && !isBool(f.Value) {
+				fs.warnAdjacentPositional(name, value, args)
+			}
+		} else {
+			if ok && isBool(f.Value) {
+				var val interface{} = true
+				if fs.boolNextToken && len(args) > 0 {
+					if b, ok := boolTokens[strings.ToLower(args[0])]; ok {
+						val, args = b, args[1:]
+					}
+				}
+
+				fs.found[name] = f
+				fs.markFromArgs(f)
+				if err := fs.setFlag(f, val); err != nil {
+					return nil, err
+				}
+				fs.audit(f, OriginArgs)
+
+				return args, nil
+			}
+
+			if !ok {
+				return nil, fmt.Errorf(fs.msgs().UnknownFlag, name)
+			}
+
+			if len(args) == 0 {
+				return nil, fmt.Errorf(fs.msgs().ExpectingValue, name)
+			}
+
+			arg, args = args[0], args[1:]
+			if strings.HasPrefix(arg, "-") {
+				return nil, fmt.Errorf(fs.msgs().ExpectingValue, name)
+			}
+
+			if err := fs.setValue(f, arg); err != nil {
+				return nil, err
+			}
+		}
+
+		return args, nil
+	}
+}
+
+// warnAdjacentPositional prints a warning if the given remaining arguments
+// start with a positional argument after the inline value of a flag.
+func (fs *FlagSet) warnAdjacentPositional(name, value string, args []string) {
+	if len(args) == 0 || len(args[0]) == 0 || strings.HasPrefix(args[0], "-") {
+		return
+	}
+
+	if fs.stopAtCommand {
+		return
+	}
+
+	if len(fs.commands) > 0 && len(fs.args) == 0 {
+		if _, ok, _ := fs.lookupCommand(args[0]); ok {
+			return
+		}
+	}
+
+	fmt.Fprintf(fs.Output(), fs.msgs().AdjacentPositional+"\n", name, value, args[0])
+}
+
+// clearsOnEmpty reports whether the given flag is a list that is cleared
+// when it's given an empty value.
+func clearsOnEmpty(f *Flag) bool {
+	return f.ClearOnEmpty && isSlice(f.Value)
+}
+
+// ApplyArg sets the flag given in the token, such as --name=value, as if it
+// was given in the arguments. It can be used before or after the flag set is
+// parsed, so values can be edited one at a time. Bool flags can be given
+// without a value, but the rest of the flags must have an inline value. List
+// flags that were already given in the arguments get the value appended, and
+// the rest replace their default or the values found in the sources.
+func (fs *FlagSet) ApplyArg(token string) error {
+	if token == "-" || token == "--" || !strings.HasPrefix(token, "-") {
+		return fmt.Errorf(fs.msgs().InvalidSyntax, token)
+	}
+
+	if fs.found == nil {
+		fs.found = make(map[string]*Flag)
+	}
+
+	// unknown flags can't be collected outside the arguments
+	ignoreUnknown := fs.ignoreUnknown
+	fs.ignoreUnknown = false
+	defer func() { fs.ignoreUnknown = ignoreUnknown }()
+
+	// the token is not part of the arguments, so its index is not recorded
+	fs.argTotal = 0
+	_, err := fs.parseNext([]string{token})
+	fs.argIdx = -1
+	return err
+}
+
+// parseBundle parses a bundle of flags of a single character given with a
+// single dash, such as -vno=out.txt for -v -n -o=out.txt. Bool flags in the
+// bundle are set to true, and the first flag taking a value takes the rest of
+// the bundle, without the leading "=" if any, or the next argument if the
+// bundle ends with it. It returns false if any of the characters is not a
+// flag, so the bundle can be treated as an unknown flag.
+func (fs *FlagSet) parseBundle(bundle string, args []string) ([]string, bool, error) {
+	var flags []*Flag
+	var value string
+	var hasValue bool
+	for i, c := range bundle {
+		f, ok := fs.argFlag(fs.longName(fs.normalizeName(string(c))))
+		if !ok {
+			return nil, false, nil
+		}
+
+		flags = append(flags, f)
+		if !isBool(f.Value) {
+			rest := bundle[i+utf8.RuneLen(c):]
+			value, hasValue = strings.TrimPrefix(rest, "="), rest != ""
+			break
+		}
+	}
+
+	last := flags[len(flags)-1]
+	if !isBool(last.Value) {
+		if !hasValue {
+			if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+				return nil, true, fmt.Errorf(fs.msgs().ExpectingValue, last.Name)
+			}
+			value, args = args[0], args[1:]
+		} else if value == "" && !isString(last.Value) && !clearsOnEmpty(last) {
+			return nil, true, fmt.Errorf(fs.msgs().InvalidSyntax, "-"+bundle)
+		}
+	}
+
+	for _, f := range flags {
+		if !isBool(f.Value) {
+			return args, true, fs.setValue(f, value)
+		}
+
+		fs.found[f.Name] = f
+		fs.markFromArgs(f)
+		if err := fs.setFlag(f, true); err != nil {
+			return nil, true, err
+		}
+		fs.audit(f, OriginArgs)
+	}
+
+	return args, true, nil
+}
+
+func (fs *FlagSet) setValue(f *Flag, value string) error {
+	if fs.found == nil {
+		fs.found = make(map[string]*Flag)
+	}
+
+	// flags given more than once keep the last value, no matter if they are
+	// given as -name or --name, and lists get all the values. Flags filled
+	// with the default or the sources are found too after parsing, so only
+	// the values given in the arguments are kept
+	if fs.origins[f.Name] != OriginArgs {
+		// the first value of a list replaces the values it may already have,
+		// such as the default, and the following ones are appended
+		clearList(f.Value)
+	}
+	fs.found[f.Name] = f
+
+	fs.markFromArgs(f)
+	if value == "" && clearsOnEmpty(f) {
+		clearList(f.Value)
+	} else if err := fs.setFlag(f, value); err != nil {
+		return err
+	}
+
+	fs.audit(f, OriginArgs)
+	return nil
+}
+
+// longName returns the name of the flag with the given shorthand or the same
+// name if it's not a shorthand.
+func (fs *FlagSet) longName(name string) string {
+	if long, ok := fs.shorthands[name]; ok {
+		return long
+	}
+	return name
+}
+
+// setShorthand sets the given shorthand for the flag with the given name. It
+// panics if the shorthand is already a flag or the shorthand of a flag.
+func (fs *FlagSet) setShorthand(name, shorthand string) {
+	if fs.shorthands == nil {
+		fs.shorthands = make(map[string]string)
+	}
+
+	name = fs.normalizeName(name)
+	shorthand = fs.normalizeName(shorthand)
+	if _, ok := fs.flags[shorthand]; ok {
+		panic(fmt.Errorf("flag %s was already defined", shorthand))
+	}
+
+	if _, ok := fs.shorthands[shorthand]; ok {
+		panic(fmt.Errorf("shorthand %s was already defined", shorthand))
+	}
+
+	fs.shorthands[shorthand] = name
+	fs.flags[name].Shorthand = shorthand
+}
+
+// negatedFlag returns the negatable flag negated by the given name in the
+// form no-name, if it's not a flag itself.
+func (fs *FlagSet) negatedFlag(name string) (*Flag, bool) {
+	if !strings.HasPrefix(name, "no-") {
+		return nil, false
+	}
+
+	if _, ok := fs.flags[name]; ok {
+		return nil, false
+	}
+
+	f, ok := fs.argFlag(name[3:])
+	if !ok || !f.Negatable || !isBool(f.Value) {
+		return nil, false
+	}
+	return f, true
+}
+
+// argFlag returns the flag with the given name, as long as it can be set
+// using the command line arguments.
+func (fs *FlagSet) argFlag(name string) (*Flag, bool) {
+	f, ok := fs.flags[name]
+	if !ok || f.SourceOnly {
+		return nil, false
+	}
+	return f, true
+}
+
+// setFlag assigns the given value to the flag after applying the flag
+// options to it.
+func (fs *FlagSet) setFlag(f *Flag, val interface{}) error {
+	if f.FIFOTimeout > 0 {
+		if s, ok := val.(string); ok && strings.HasPrefix(s, fifoPrefix) {
+			var err error
+			val, err = readFIFO(s[len(fifoPrefix):], f.FIFOTimeout)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if f.MustBeUTF8 && isString(f.Value) && !validUTF8(val) {
+		return fmt.Errorf(fs.msgs().InvalidUTF8, f.Name)
+	}
+
+	if f.AllowGrouping && isInteger(f.Value) {
+		if s, ok := val.(string); ok {
+			val = stripDigitSeparators(s, ',')
+		}
+	}
+
+	if f.AllowExpressions && (isInteger(f.Value) || isFloat(f.Value)) {
+		if s, ok := val.(string); ok {
+			var err error
+			if val, err = evalExpr(s, isInteger(f.Value)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return f.Value.Set(val)
+}
+
+// sourceValue is the Value given to the extractors when filling a flag from
+// the sources, so the flag options are applied to the source values too.
+type sourceValue struct {
+	fs *FlagSet
+	f  *Flag
+}
+
+func (v sourceValue) Set(val interface{}) error {
+	if v.f.TrimSpace || v.fs.trimSpace {
+		val = trimSpace(val)
+	}
+	if v.f.ExpandEnv {
+		val = expandEnv(val)
+	}
+	if v.f.DurationSeconds && isDuration(v.f.Value) {
+		val = secondsToDuration(val)
+	}
+	if v.f.EmptyIsTrue && isBool(v.f.Value) && isEmptyString(val) {
+		val = true
+	}
+	if v.f.LenientBool && isBool(v.f.Value) {
+		val = lenientBool(val)
+	}
+	if len(v.f.SplitOn) > 0 && isSlice(v.f.Value) {
+		val = splitValue(val, v.f.SplitOn)
+	}
+	return v.fs.setFlag(v.f, val)
+}
+
+var lenientBools = map[string]bool{
+	"yes": true,
+	"on":  true,
+	"no":  false,
+	"off": false,
+}
+
+// lenientBool returns the bool of the given value if it's one of the lenient
+// spellings of a bool, or the value as it is otherwise.
+func lenientBool(val interface{}) interface{} {
+	var s string
+	switch v := val.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return val
+	}
+
+	if b, ok := lenientBools[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return b
+	}
+	return val
+}
+
+// splitValue splits the given value on any of the given separators if it's
+// a string, trimming the spaces around the elements and dropping the empty
+// ones.
+func splitValue(val interface{}, seps []string) interface{} {
+	var s string
+	switch v := val.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return val
+	}
+
+	var parts = []string{s}
+	for _, sep := range seps {
+		var split []string
+		for _, p := range parts {
+			split = append(split, strings.Split(p, sep)...)
+		}
+		parts = split
+	}
+
+	var values = make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			values = append(values, p)
+		}
+	}
+	return values
+}
+
+func isEmptyString(val interface{}) bool {
+	switch val := val.(type) {
+	case string:
+		return val == ""
+	case []byte:
+		return len(val) == 0
+	default:
+		return false
+	}
+}
+
+// validUTF8 reports whether the given value is valid UTF-8 if it's a string
+// or a list of strings.
+func validUTF8(val interface{}) bool {
+	switch val := val.(type) {
+	case string:
+		return utf8.ValidString(val)
+	case []byte:
+		return utf8.Valid(val)
+	case []string:
+		for _, s := range val {
+			if !utf8.ValidString(s) {
+				return false
+			}
+		}
+	case []interface{}:
+		for _, v := range val {
+			if !validUTF8(v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// trimSpace trims the spaces around the given value if it's a string or a
+// list of strings.
+func trimSpace(val interface{}) interface{} {
+	switch val := val.(type) {
+	case string:
+		return strings.TrimSpace(val)
+	case []byte:
+		return strings.TrimSpace(string(val))
+	case []string:
+		var trimmed = make([]string, len(val))
+		for i, s := range val {
+			trimmed[i] = strings.TrimSpace(s)
+		}
+		return trimmed
+	case []interface{}:
+		var trimmed = make([]interface{}, len(val))
+		for i, v := range val {
+			trimmed[i] = trimSpace(v)
+		}
+		return trimmed
+	default:
+		return val
+	}
+}
+
+// expandEnv expands the references to environment variables in the given
+// value if it's a string or a list of strings.
+func expandEnv(val interface{}) interface{} {
+	switch val := val.(type) {
+	case string:
+		return os.ExpandEnv(val)
+	case []byte:
+		return os.ExpandEnv(string(val))
+	case []string:
+		var expanded = make([]string, len(val))
+		for i, s := range val {
+			expanded[i] = os.ExpandEnv(s)
+		}
+		return expanded
+	case []interface{}:
+		var expanded = make([]interface{}, len(val))
+		for i, v := range val {
+			expanded[i] = expandEnv(v)
+		}
+		return expanded
+	default:
+		return val
+	}
+}
+
+// Parsed returns whether the flag set has already been parsed.
+func (fs *FlagSet) Parsed() bool {
+	return fs.parsed
+}
+
+// SetRequireParse sets whether the flag set must be parsed before using its
+// accessors. If it's enabled, NFlags, NArg, Args, Arg and UnknownArgs will
+// panic when they are called before Parse, to catch initialization order
+// mistakes.
+func (fs *FlagSet) SetRequireParse(require bool) { fs.requireParse = require }
+
+// ErrNotParsed is the panic value of the accessors of a flag set that is
+// required to be parsed before they are used.
+var ErrNotParsed = fmt.Errorf("flagga: flag set accessed before being parsed")
+
+func (fs *FlagSet) checkParsed() {
+	if fs.requireParse && !fs.parsed {
+		panic(ErrNotParsed)
+	}
+}
+
+// NFlags returns the number of flags that have been filled.
+func (fs *FlagSet) NFlags() int {
+	fs.checkParsed()
+	return len(fs.found)
+}
+
+// NArg returns the number of arguments that have been found.
+func (fs *FlagSet) NArg() int {
+	fs.checkParsed()
+	return len(fs.args)
+}
 
 // Args returns the arguments that have been found.
-func (fs *FlagSet) Args() []string { return fs.args }
+func (fs *FlagSet) Args() []string {
+	fs.checkParsed()
+	return fs.args
+}
 
 // Arg returns the nth argument that has been found.
 func (fs *FlagSet) Arg(i int) string {
+	fs.checkParsed()
 	if i < 0 || i >= len(fs.args) {
 		return ""
 	}
-	return fs.args[i]
+	return fs.args[i]
+}
+
+// DeprecatedAlias makes the arguments accept the name oldName for the flag
+// named newName, so a flag can be renamed without breaking the programs
+// using the old name. The first time the old name is given, a warning telling
+// to use the new name is printed to the output of the flag set. It panics if
+// the new flag is not defined or the old name is already a flag.
+func (fs *FlagSet) DeprecatedAlias(oldName, newName string) {
+	oldName, newName = fs.normalizeName(oldName), fs.normalizeName(newName)
+	if _, ok := fs.flags[newName]; !ok {
+		panic(fmt.Errorf("flag %s is not defined", newName))
+	}
+
+	if _, ok := fs.flags[oldName]; ok {
+		panic(fmt.Errorf("flag %s was already defined", oldName))
+	}
+
+	if fs.deprecated == nil {
+		fs.deprecated = make(map[string]string)
+	}
+	fs.deprecated[oldName] = newName
+}
+
+// undeprecatedName returns the name of the flag with the given deprecated
+// name, warning about it the first time, or the same name if it's not
+// deprecated.
+func (fs *FlagSet) undeprecatedName(name string) string {
+	newName, ok := fs.deprecated[name]
+	if !ok {
+		return name
+	}
+
+	if !fs.warned[name] {
+		if fs.warned == nil {
+			fs.warned = make(map[string]bool)
+		}
+		fs.warned[name] = true
+		fmt.Fprintf(fs.Output(), fs.msgs().Deprecated+"\n", name, newName)
+	}
+
+	return newName
+}
+
+// AliasValue defines a flag with the given name that works like a bool flag
+// and appends the given values to the list flag named target every time it's
+// given in the arguments. For example, --enable-all can append every feature
+// to the --enable-feature list. It panics if the target is not a defined list
+// flag.
+func (fs *FlagSet) AliasValue(name, target string, values ...string) {
+	f, ok := fs.flags[fs.normalizeName(target)]
+	if !ok {
+		panic(fmt.Errorf("flag %s is not defined", target))
+	}
+
+	if !isSlice(f.Value) {
+		panic(fmt.Errorf("flag %s is not a list", target))
+	}
+
+	usage := fmt.Sprintf("alias of -%s %s", f.Name, strings.Join(values, ","))
+	fs.addFlag(name, false, usage, aliasValue{fs, f.Name, values}, nil)
+}
+
+// SetShortCircuit makes parsing stop as soon as the arguments are parsed if
+// the flag with the given name is given in them, returning the given error.
+// Neither sources nor required flags are checked, so flags such as --version
+// can be handled without failing because other flags are missing. It panics
+// if the flag is not defined.
+func (fs *FlagSet) SetShortCircuit(name string, err error) {
+	name = fs.normalizeName(name)
+	if _, ok := fs.flags[name]; !ok {
+		panic(fmt.Errorf("flag %s is not defined", name))
+	}
+
+	if fs.shortCircuits == nil {
+		fs.shortCircuits = make(map[string]error)
+	}
+	fs.shortCircuits[name] = err
+}
+
+// AllOrNone makes parsing fail if some, but not all, of the flags with the
+// given names are given, either in the arguments or in the sources. It can be
+// used for flags that only make sense together, such as the certificate and
+// the key of a TLS configuration. It panics if any of the flags is not
+// defined.
+func (fs *FlagSet) AllOrNone(names ...string) {
+	var group = make([]string, len(names))
+	for i, name := range names {
+		group[i] = fs.normalizeName(name)
+		if _, ok := fs.flags[group[i]]; !ok {
+			panic(fmt.Errorf("flag %s is not defined", group[i]))
+		}
+	}
+
+	fs.allOrNone = append(fs.allOrNone, group)
+}
+
+// RequireOverride makes parsing fail if the flag with the given name is not
+// given or its value is its default value, so placeholder defaults such as
+// "CHANGEME" can be documented in the usage but never used. It panics if the
+// flag is not defined.
+func (fs *FlagSet) RequireOverride(name string) {
+	name = fs.normalizeName(name)
+	if _, ok := fs.flags[name]; !ok {
+		panic(fmt.Errorf("flag %s is not defined", name))
+	}
+	fs.overrides = append(fs.overrides, name)
+}
+
+// isDefault reports whether the given flag was not given or has its default
+// value.
+func (fs *FlagSet) isDefault(f *Flag) bool {
+	if fs.origins[f.Name] != OriginArgs && fs.origins[f.Name] != OriginSource {
+		return true
+	}
+
+	g, ok := f.Value.(Getter)
+	return ok && reflect.DeepEqual(g.Get(), f.Default)
+}
+
+// AddCrossValidator adds a function that checks the values of several flags
+// together, such as a maximum that must not be lower than a minimum. The
+// validators are called in the order they were added once all the flags of
+// the flag set are filled, and the first error they return is handled
+// according to the error handling policy of the flag set. The values can be
+// read with Lookup.
+func (fs *FlagSet) AddCrossValidator(fn func(*FlagSet) error) {
+	fs.validators = append(fs.validators, fn)
+}
+
+// FlagOrPositional makes the flag with the given name take its value from
+// the positional argument at the given index if it's not given in the
+// arguments, so either tool --file x or tool x can be used. Parsing fails if
+// both are given. The positional argument is still returned by Args. It
+// panics if the flag is not defined.
+func (fs *FlagSet) FlagOrPositional(flagName string, posIndex int) {
+	flagName = fs.normalizeName(flagName)
+	if _, ok := fs.flags[flagName]; !ok {
+		panic(fmt.Errorf("flag %s is not defined", flagName))
+	}
+
+	if fs.flagPositional == nil {
+		fs.flagPositional = make(map[string]int)
+	}
+	fs.flagPositional[flagName] = posIndex
+}
+
+// bindFlagPositionals fills the flags that can be given as positional
+// arguments with them, as long as they were not given as flags.
+func (fs *FlagSet) bindFlagPositionals() error {
+	for _, name := range fs.flagOrder {
+		idx, ok := fs.flagPositional[name]
+		if !ok || idx < 0 || idx >= len(fs.args) {
+			continue
+		}
+
+		if fs.origins[name] == OriginArgs {
+			return fmt.Errorf(fs.msgs().FlagAndPositional, name, idx)
+		}
+
+		fs.argIdx = fs.argPositions[idx]
+		err := fs.setValue(fs.flags[name], fs.args[idx])
+		fs.argIdx = -1
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ConfigFlag makes the string list flag with the given name provide the
+// config files used as sources. Once the arguments are parsed, a source is
+// created with FileVia for each one of the files given in the arguments, or
+// the default files of the flag if it's not given, so their format is chosen
+// by their extension. The default files are optional, so they provide no
+// values if they don't exist. Files given later take precedence over the ones
+// given before, and the sources given to Parse take precedence over all of
+// them. It panics if the flag is not defined or it's not a string list flag.
+func (fs *FlagSet) ConfigFlag(name string) {
+	name = fs.normalizeName(name)
+	f, ok := fs.flags[name]
+	if !ok {
+		panic(fmt.Errorf("flag %s is not defined", name))
+	}
+
+	if _, ok := f.Default.([]string); !ok {
+		panic(fmt.Errorf("flag %s is not a string list", name))
+	}
+	fs.configFlag = name
+}
+
+// configSources returns the sources of the config files in the value of the
+// config flag, from the last one to the first. If the flag was not given in
+// the arguments, its default files are used, and they are optional.
+func (fs *FlagSet) configSources() ([]Source, error) {
+	f := fs.flags[fs.configFlag]
+	files, _ := f.Default.([]string)
+	_, given := fs.found[fs.configFlag]
+	if given {
+		files = f.Value.(Getter).Get().([]string)
+	}
+
+	var sources = make([]Source, len(files))
+	for i, file := range files {
+		s, err := FileVia(file)
+		if err != nil {
+			return nil, err
+		}
+
+		if !given {
+			s = Optional(s)
+		}
+		sources[len(files)-1-i] = s
+	}
+
+	return sources, nil
+}
+
+// markFromArgs records that the given flag was given in the argument being
+// parsed.
+func (fs *FlagSet) markFromArgs(f *Flag) {
+	fs.setOrigin(f.Name, OriginArgs)
+	if fs.argIdx < 0 {
+		return
+	}
+
+	if fs.argIndexes == nil {
+		fs.argIndexes = make(map[string]int)
+	}
+	fs.argIndexes[f.Name] = fs.argIdx
+}
+
+// ArgIndex returns the index in the arguments given to the flag set of the
+// flag or the declared positional argument with the given name, so they can
+// be mapped back to the command line. For flags given more than once, it's
+// the index of the last one, and for variadic arguments, the index of the
+// first argument they take. It returns -1 if they were not given in the
+// arguments.
+func (fs *FlagSet) ArgIndex(name string) int {
+	if f := fs.Lookup(name); f != nil {
+		if idx, ok := fs.argIndexes[f.Name]; ok {
+			return idx
+		}
+		return -1
+	}
+
+	if idx, ok := fs.positionalArgs[name]; ok && idx < len(fs.argPositions) {
+		return fs.argPositions[idx]
+	}
+	return -1
+}
+
+func (fs *FlagSet) setOrigin(name string, o Origin) {
+	if fs.origins == nil {
+		fs.origins = make(map[string]Origin)
+	}
+	fs.origins[name] = o
+}
+
+// flagIndex returns the position of the flag with the given name in the
+// order the flags were defined.
+func (fs *FlagSet) flagIndex(name string) int {
+	for i, n := range fs.flagOrder {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// SourceOnly marks the flags with the given names as flags that can only be
+// filled using the sources, for example to enforce that secrets are not
+// given in the command line. It panics if any of the flags is not defined.
+func (fs *FlagSet) SourceOnly(names ...string) {
+	for _, name := range names {
+		f, ok := fs.flags[fs.normalizeName(name)]
+		if !ok {
+			panic(fmt.Errorf("flag %s is not defined", name))
+		}
+		f.SourceOnly = true
+	}
+}
+
+// SetIgnoreUnknown sets whether unknown flags should be ignored instead of
+// returning an error. Ignored flags are collected in order, along with their
+// values, and can be retrieved using UnknownArgs. When an unknown flag has no
+// inline value, the next argument is considered its value unless it starts
+// with a dash.
+func (fs *FlagSet) SetIgnoreUnknown(ignore bool) { fs.ignoreUnknown = ignore }
+
+// SetUnknownHandler sets a function that is called with the name and the
+// value of each unknown flag found while parsing, instead of failing. The
+// value is the inline value of the flag or, if it has none, the next argument
+// as long as it doesn't look like a flag, and it's empty otherwise. If the
+// function returns an error, parsing fails with it. The handler takes
+// precedence over SetIgnoreUnknown, so unknown flags handled by it are not
+// collected.
+func (fs *FlagSet) SetUnknownHandler(fn func(name, value string) error) {
+	fs.unknownHandler = fn
+}
+
+// UnknownArgs returns the unknown flags and their values found while parsing,
+// in the same order they were given, so they can be forwarded to another
+// program. Unknown flags are only collected if SetIgnoreUnknown is enabled.
+func (fs *FlagSet) UnknownArgs() []string {
+	fs.checkParsed()
+	return fs.unknown
+}
+
+// ApplyDefaults replaces the default values of the flags with the values of
+// the given map, keyed by flag name. Values are set with the Value of the
+// flag they are the default of, so they are converted and validated as the
+// values found in the sources are. If any of the names is not a defined flag
+// or any value can't be set an error is returned and no default is changed.
+func (fs *FlagSet) ApplyDefaults(defaults map[string]interface{}) error {
+	var names = make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var flags = make([]*Flag, 0, len(names))
+	for _, name := range names {
+		f, ok := fs.flags[fs.normalizeName(name)]
+		if !ok {
+			return fmt.Errorf(fs.msgs().UnknownFlag, name)
+		}
+		flags = append(flags, f)
+	}
+
+	var converted = make([]interface{}, len(flags))
+	for i, f := range flags {
+		if err := fs.setFlag(f, defaults[names[i]]); err != nil {
+			for _, f := range flags[:i] {
+				_ = f.Value.Set(f.Default)
+			}
+			return fmt.Errorf(fs.msgs().InvalidDefault, names[i], err)
+		}
+		converted[i] = defaultOf(f, defaults[names[i]])
+	}
+
+	for i, f := range flags {
+		f.Default = converted[i]
+		// the value holds its new default, as values of the standard
+		// library do when they are defined
+		if v, ok := f.Value.(stdValue); ok {
+			f.Value = stdValue{v.value, v.value.String()}
+		}
+	}
+
+	return nil
+}
+
+// defaultOf returns the value the given flag holds after being set to the
+// given default value.
+func defaultOf(f *Flag, val interface{}) interface{} {
+	switch v := f.Value.(type) {
+	case stdValue:
+		return snapshot(v.Get())
+	case Getter:
+		return snapshot(v.Get())
+	default:
+		return val
+	}
+}
+
+// ResetFlag sets the flag with the given name back to its default value, as
+// if it was not given in the arguments nor found in the sources, so it's no
+// longer found and has no origin. An error is returned if the flag is not
+// defined.
+func (fs *FlagSet) ResetFlag(name string) error {
+	name = fs.normalizeName(name)
+	f, ok := fs.flags[name]
+	if !ok {
+		return fmt.Errorf(fs.msgs().UnknownFlag, name)
+	}
+
+	if err := f.Value.Set(f.Default); err != nil {
+		return err
+	}
+
+	delete(fs.found, name)
+	delete(fs.origins, name)
+	delete(fs.argIndexes, name)
+	return nil
+}
+
+// Lookup returns the defined flag with the given name. It will return nil if
+// it's not found.
+func (fs *FlagSet) Lookup(name string) *Flag {
+	return fs.flags[fs.normalizeName(name)]
+}
+
+// Name returns the given name to this flag set.
+func (fs *FlagSet) Name() string { return fs.name }
+
+// Description returns the given description to this flag set.
+func (fs *FlagSet) Description() string { return fs.description }
+
+// SetOutput sets the destination writer for the usage and error messages.
+func (fs *FlagSet) SetOutput(w io.Writer) { fs.out = w }
+
+// Output returns the destination writer for the usage and error messages. If
+// no output was set, the default is the output of the parent flag set for
+// subcommands and os.Stderr for the rest.
+func (fs *FlagSet) Output() io.Writer {
+	if fs.out == nil && fs.parent != nil {
+		return fs.parent.Output()
+	}
+
+	if fs.out == nil {
+		return os.Stderr
+	}
+	return fs.out
+}
+
+// SubCommand defines a subcommand with the given name and description and
+// returns the flag set in which the flags of the subcommand can be defined.
+// If the first positional argument is the name of a subcommand, the rest of
+// the arguments are parsed by its flag set using the same sources, once the
+// flags of the parent are filled. Subcommands inherit the error handling
+// policy and the output of their parent.
+func (fs *FlagSet) SubCommand(name, description string) *FlagSet {
+	if fs.commands == nil {
+		fs.commands = make(map[string]*FlagSet)
+	}
+
+	if _, ok := fs.commands[name]; ok {
+		panic(fmt.Errorf("subcommand %s was already defined", name))
+	}
+
+	cmd := NewFlagSet(name, description, fs.errorHandling)
+	cmd.parent = fs
+	fs.commandOrder = append(fs.commandOrder, name)
+	fs.commands[name] = cmd
+	return cmd
+}
+
+// SubCommands returns the flag sets of the defined subcommands in the order
+// they were defined.
+func (fs *FlagSet) SubCommands() []*FlagSet {
+	var cmds = make([]*FlagSet, len(fs.commandOrder))
+	for i, name := range fs.commandOrder {
+		cmds[i] = fs.commands[name]
+	}
+	return cmds
 }
 
-// Lookup returns the defined flag with the given name. It will return nil if
-// it's not found.
-func (fs *FlagSet) Lookup(name string) *Flag { return fs.flags[name] }
+// Parent returns the flag set the subcommand was defined in or nil if the
+// flag set is not a subcommand.
+func (fs *FlagSet) Parent() *FlagSet { return fs.parent }
+
+// Command returns the flag set of the subcommand chosen in the arguments or
+// nil if no subcommand was chosen.
+func (fs *FlagSet) Command() *FlagSet { return fs.command }
+
+// SetRequireSubcommand sets whether a subcommand must be given. If it's
+// enabled, the first positional argument must be the name of one of the
+// defined subcommands, instead of being treated as a regular argument when
+// it's not.
+func (fs *FlagSet) SetRequireSubcommand(require bool) { fs.requireCommand = require }
+
+// SetAllowAbbrev sets whether subcommands can be chosen using an unambiguous
+// prefix of their name, so "mig" chooses the migrate subcommand unless
+// another subcommand starts with "mig" too, in which case parsing fails.
+func (fs *FlagSet) SetAllowAbbrev(allow bool) { fs.allowAbbrev = allow }
+
+// lookupCommand returns the subcommand with the given name or, if
+// abbreviations are allowed, the only one starting with it.
+func (fs *FlagSet) lookupCommand(name string) (*FlagSet, bool, error) {
+	if cmd, ok := fs.commands[name]; ok || !fs.allowAbbrev {
+		return cmd, ok, nil
+	}
 
-// Name returns the given name to this flag set.
-func (fs *FlagSet) Name() string { return fs.name }
+	var matches []string
+	for _, cmd := range fs.commandOrder {
+		if strings.HasPrefix(cmd, name) {
+			matches = append(matches, cmd)
+		}
+	}
 
-// Description returns the given description to this flag set.
-func (fs *FlagSet) Description() string { return fs.description }
+	switch len(matches) {
+	case 0:
+		return nil, false, nil
+	case 1:
+		return fs.commands[matches[0]], true, nil
+	default:
+		err := fmt.Errorf(fs.msgs().AmbiguousSubCommand, name, strings.Join(matches, ", "))
+		return nil, false, err
+	}
+}
 
-// SetOutput sets the destination writer for the usage and error messages.
-func (fs *FlagSet) SetOutput(w io.Writer) { fs.out = w }
+// SetAllowInlineValues sets whether flags can be given with an inline value,
+// such as --name=value. It's enabled by default. If it's disabled, inline
+// values are invalid syntax and values must be given as the next argument,
+// such as --name value.
+func (fs *FlagSet) SetAllowInlineValues(allow bool) { fs.noInlineValues = !allow }
+
+// SetWarnAdjacentPositional sets whether a warning is printed when a flag with
+// an inline value is followed by a positional argument, such as
+// --name=john doe, which is often a value that was meant to be quoted. The
+// argument is still a positional argument. It's disabled by default and
+// doesn't apply to bool flags or to arguments choosing a subcommand.
+func (fs *FlagSet) SetWarnAdjacentPositional(warn bool) { fs.warnAdjacent = warn }
+
+// SetTrimSpace sets whether the spaces and newlines around the string values
+// found in the sources are trimmed for all the flags, as the TrimSpace option
+// of the flags does.
+func (fs *FlagSet) SetTrimSpace(trim bool) { fs.trimSpace = trim }
+
+// SetAllowBundling sets whether flags of a single character can be bundled
+// after a single dash, as with getopt, so -vno=out.txt is the same as -v -n
+// -o=out.txt when v and n are bool flags and o takes a value. The first flag
+// of the bundle taking a value takes the rest of the bundle as its value, or
+// the next argument if it's the last one. A flag whose name is the whole
+// bundle, such as -vno, always takes precedence over the bundle, and bundles
+// with a character that is not a flag are unknown flags.
+func (fs *FlagSet) SetAllowBundling(allow bool) { fs.allowBundling = allow }
+
+// SetAllowRedefine sets whether defining a flag with the name of an already
+// defined flag replaces the previous definition, keeping its position in the
+// usage, instead of panicking.
+func (fs *FlagSet) SetAllowRedefine(allow bool) { fs.allowRedefine = allow }
+
+// SetBoolNextToken sets whether bool flags given without an inline value take
+// the next argument as their value if it's true, false, yes or no, so they
+// can be given as --verbose false. Any other argument after a bool flag is
+// not taken as its value.
+func (fs *FlagSet) SetBoolNextToken(enabled bool) { fs.boolNextToken = enabled }
+
+var boolTokens = map[string]bool{
+	"true":  true,
+	"yes":   true,
+	"false": false,
+	"no":    false,
+}
 
-// Output returns the destination writer for the usage and error messages. If
-// no output was set, the default is os.Stderr.
-func (fs *FlagSet) Output() io.Writer {
-	if fs.out == nil {
-		return os.Stderr
+// Freeze prevents any other flag from being defined in the flag set. Defining
+// a flag after the flag set is frozen panics, which catches flags defined
+// dynamically after the flag set is parsed.
+func (fs *FlagSet) Freeze() { fs.frozen = true }
+
+// SetNormalizeFunc sets the function used to normalize flag names, both when
+// they are defined and when they are matched while parsing, so that different
+// spellings of a name resolve to the same flag. For example, a function
+// replacing underscores with dashes makes --log_level and --log-level the
+// same flag. It must be set before defining any flag.
+func (fs *FlagSet) SetNormalizeFunc(fn func(string) string) { fs.normalize = fn }
+
+// WithKeyPrefix calls fn to define the flags of a nested config section,
+// prepending the given prefix to the keys of the JSON, Config and other
+// config file extractors of the flags defined in it. For example, the
+// extractor JSON("host") of a flag defined with the prefix "db." matches the
+// key "db.host", which is the host key inside the db object of a JSON file.
+// Environment and registry extractors are left untouched. Calls can be
+// nested, in which case the prefixes are concatenated.
+func (fs *FlagSet) WithKeyPrefix(prefix string, fn func(g *FlagSet)) {
+	parent := fs.keyPrefix
+	fs.keyPrefix = parent + prefix
+	defer func() { fs.keyPrefix = parent }()
+	fn(fs)
+}
+
+func (fs *FlagSet) normalizeName(name string) string {
+	if fs.normalize == nil {
+		return name
 	}
-	return fs.out
+	return fs.normalize(name)
 }
 
 func (fs *FlagSet) addFlag(
@@ -413,11 +2335,26 @@ func (fs *FlagSet) addFlag(
 		fs.flags = make(map[string]*Flag)
 	}
 
+	name = fs.normalizeName(name)
+	if fs.frozen {
+		panic(fmt.Errorf("flag %s defined after the flag set was frozen", name))
+	}
+
 	if _, ok := fs.flags[name]; ok {
-		panic(fmt.Errorf("flag %s was already defined", name))
+		if !fs.allowRedefine {
+			panic(fmt.Errorf("flag %s was already defined", name))
+		}
+	} else {
+		fs.flagOrder = append(fs.flagOrder, name)
 	}
 
-	fs.flagOrder = append(fs.flagOrder, name)
+	if fs.keyPrefix != "" {
+		prefixed := make([]Extractor, len(extractors))
+		for i, e := range extractors {
+			prefixed[i] = prefixKey(e, fs.keyPrefix)
+		}
+		extractors = prefixed
+	}
 
 	fs.flags[name] = &Flag{
 		Name:       name,
@@ -453,7 +2390,9 @@ func (fs *FlagSet) Int(
 }
 
 // Bool adds a new bool flag and returns a pointer to the value that will
-// be filled once the flag set is parsed.
+// be filled once the flag set is parsed. The flag is set to true if it's
+// given without a value, and it accepts any inline value accepted by
+// strconv.ParseBool, such as -b=0, -b=1, -b=true or -b=false.
 func (fs *FlagSet) Bool(
 	name string,
 	usage string,
@@ -464,6 +2403,20 @@ func (fs *FlagSet) Bool(
 	return v
 }
 
+// NegatableBool adds a new bool flag that can be given as -name to set it to
+// true and as -no-name to set it to false, and returns a pointer to the value
+// that will be filled once the flag set is parsed.
+func (fs *FlagSet) NegatableBool(
+	name string,
+	usage string,
+	extractors ...Extractor,
+) *bool {
+	v := new(bool)
+	fs.BoolVar(v, name, usage, extractors...)
+	fs.flags[fs.normalizeName(name)].Negatable = true
+	return v
+}
+
 // Int64 adds a new int64 flag and returns a pointer to the value that will
 // be filled once the flag set is parsed.
 func (fs *FlagSet) Int64(
@@ -529,6 +2482,69 @@ func (fs *FlagSet) Duration(
 	return v
 }
 
+// TimeOfDay adds a new time of day flag and returns a pointer to the value
+// that will be filled once the flag set is parsed. Times of day can be given
+// as HH:MM or HH:MM:SS.
+func (fs *FlagSet) TimeOfDay(
+	name string,
+	defaultValue TimeOfDay,
+	usage string,
+	extractors ...Extractor,
+) *TimeOfDay {
+	v := new(TimeOfDay)
+	fs.TimeOfDayVar(v, name, defaultValue, usage, extractors...)
+	return v
+}
+
+// Time adds a new time flag and returns a pointer to the value that will be
+// filled once the flag set is parsed. Times can be given as strings in the
+// given layout, such as time.RFC3339, which is used if the layout is empty,
+// or as seconds since the Unix epoch in the sources.
+func (fs *FlagSet) Time(
+	name string,
+	defaultValue time.Time,
+	usage string,
+	layout string,
+	extractors ...Extractor,
+) *time.Time {
+	v := new(time.Time)
+	fs.TimeVar(v, name, defaultValue, usage, layout, extractors...)
+	return v
+}
+
+// Rate adds a new rate flag and returns a pointer to the value that will be
+// filled once the flag set is parsed. Rates are expressed in events per
+// second and can be given as N/s, N/m or N/h.
+func (fs *FlagSet) Rate(
+	name string,
+	defaultValue float64,
+	usage string,
+	extractors ...Extractor,
+) *float64 {
+	v := new(float64)
+	fs.RateVar(v, name, defaultValue, usage, extractors...)
+	return v
+}
+
+// Enum adds a new string flag whose value must be one of the given choices
+// and returns a pointer to the value that will be filled once the flag set is
+// parsed. If ignoreCase is set, values match the choices no matter their
+// case, so INFO and info both match the choice info, and the value is the
+// choice as it's written in the choices. It panics if the default value is
+// not one of the choices.
+func (fs *FlagSet) Enum(
+	name string,
+	defaultValue string,
+	choices []string,
+	ignoreCase bool,
+	usage string,
+	extractors ...Extractor,
+) *string {
+	v := new(string)
+	fs.EnumVar(v, name, defaultValue, choices, ignoreCase, usage, extractors...)
+	return v
+}
+
 // StringList adds a new []string flag and returns a pointer to the value
 // that will be filled once the flag set is parsed.
 func (fs *FlagSet) StringList(
@@ -608,7 +2624,11 @@ func (fs *FlagSet) Uint64List(
 }
 
 // DurationList adds a new []time.Duration flag and returns a pointer to the
-// value that will be filled once the flag set is parsed.
+// value that will be filled once the flag set is parsed. Each element of the
+// lists found in the sources is converted on its own: strings must have a
+// unit, as accepted by time.ParseDuration, and numbers are nanoseconds, or
+// seconds if DurationSeconds is set. So ["1s", 2000000000, "3m"] in a JSON
+// file is 1s, 2s and 3m.
 func (fs *FlagSet) DurationList(
 	name string,
 	defaultValue []time.Duration,
@@ -620,6 +2640,204 @@ func (fs *FlagSet) DurationList(
 	return v
 }
 
+// StringP is like String, but also accepts the given shorthand as the name of
+// the flag.
+func (fs *FlagSet) StringP(
+	name, shorthand string,
+	defaultValue string,
+	usage string,
+	extractors ...Extractor,
+) *string {
+	v := fs.String(name, defaultValue, usage, extractors...)
+	fs.setShorthand(name, shorthand)
+	return v
+}
+
+// BoolP is like Bool, but also accepts the given shorthand as the name of the
+// flag.
+func (fs *FlagSet) BoolP(
+	name, shorthand string,
+	usage string,
+	extractors ...Extractor,
+) *bool {
+	v := fs.Bool(name, usage, extractors...)
+	fs.setShorthand(name, shorthand)
+	return v
+}
+
+// IntP is like Int, but also accepts the given shorthand as the name of the
+// flag.
+func (fs *FlagSet) IntP(
+	name, shorthand string,
+	defaultValue int,
+	usage string,
+	extractors ...Extractor,
+) *int {
+	v := fs.Int(name, defaultValue, usage, extractors...)
+	fs.setShorthand(name, shorthand)
+	return v
+}
+
+// Int64P is like Int64, but also accepts the given shorthand as the name of the
+// flag.
+func (fs *FlagSet) Int64P(
+	name, shorthand string,
+	defaultValue int64,
+	usage string,
+	extractors ...Extractor,
+) *int64 {
+	v := fs.Int64(name, defaultValue, usage, extractors...)
+	fs.setShorthand(name, shorthand)
+	return v
+}
+
+// UintP is like Uint, but also accepts the given shorthand as the name of the
+// flag.
+func (fs *FlagSet) UintP(
+	name, shorthand string,
+	defaultValue uint,
+	usage string,
+	extractors ...Extractor,
+) *uint {
+	v := fs.Uint(name, defaultValue, usage, extractors...)
+	fs.setShorthand(name, shorthand)
+	return v
+}
+
+// Uint64P is like Uint64, but also accepts the given shorthand as the name of
+// the flag.
+func (fs *FlagSet) Uint64P(
+	name, shorthand string,
+	defaultValue uint64,
+	usage string,
+	extractors ...Extractor,
+) *uint64 {
+	v := fs.Uint64(name, defaultValue, usage, extractors...)
+	fs.setShorthand(name, shorthand)
+	return v
+}
+
+// FloatP is like Float, but also accepts the given shorthand as the name of the
+// flag.
+func (fs *FlagSet) FloatP(
+	name, shorthand string,
+	defaultValue float64,
+	usage string,
+	extractors ...Extractor,
+) *float64 {
+	v := fs.Float(name, defaultValue, usage, extractors...)
+	fs.setShorthand(name, shorthand)
+	return v
+}
+
+// DurationP is like Duration, but also accepts the given shorthand as the name
+// of the flag.
+func (fs *FlagSet) DurationP(
+	name, shorthand string,
+	defaultValue time.Duration,
+	usage string,
+	extractors ...Extractor,
+) *time.Duration {
+	v := fs.Duration(name, defaultValue, usage, extractors...)
+	fs.setShorthand(name, shorthand)
+	return v
+}
+
+// StringListP is like StringList, but also accepts the given shorthand as the
+// name of the flag.
+func (fs *FlagSet) StringListP(
+	name, shorthand string,
+	defaultValue []string,
+	usage string,
+	extractors ...Extractor,
+) *[]string {
+	v := fs.StringList(name, defaultValue, usage, extractors...)
+	fs.setShorthand(name, shorthand)
+	return v
+}
+
+// IP adds a new net.IP flag and returns a pointer to the value that will be
+// filled once the flag set is parsed.
+func (fs *FlagSet) IP(
+	name string,
+	defaultValue net.IP,
+	usage string,
+	extractors ...Extractor,
+) *net.IP {
+	v := new(net.IP)
+	fs.IPVar(v, name, defaultValue, usage, extractors...)
+	return v
+}
+
+// IPList adds a new []net.IP flag and returns a pointer to the value that
+// will be filled once the flag set is parsed. Besides repeating the flag,
+// several addresses can be given separated by commas.
+func (fs *FlagSet) IPList(
+	name string,
+	defaultValue []net.IP,
+	usage string,
+	extractors ...Extractor,
+) *[]net.IP {
+	v := new([]net.IP)
+	fs.IPListVar(v, name, defaultValue, usage, extractors...)
+	return v
+}
+
+// URL adds a new url.URL flag and returns a pointer to the value that will
+// be filled once the flag set is parsed.
+func (fs *FlagSet) URL(
+	name string,
+	defaultValue url.URL,
+	usage string,
+	extractors ...Extractor,
+) *url.URL {
+	v := new(url.URL)
+	fs.URLVar(v, name, defaultValue, usage, extractors...)
+	return v
+}
+
+// URLList adds a new []url.URL flag and returns a pointer to the value that
+// will be filled once the flag set is parsed. Besides repeating the flag,
+// several URLs can be given separated by commas.
+func (fs *FlagSet) URLList(
+	name string,
+	defaultValue []url.URL,
+	usage string,
+	extractors ...Extractor,
+) *[]url.URL {
+	v := new([]url.URL)
+	fs.URLListVar(v, name, defaultValue, usage, extractors...)
+	return v
+}
+
+// IntMap adds a new map[string]int flag and returns a pointer to the value
+// that will be filled once the flag set is parsed. Values are given as
+// key=value pairs, repeating the flag for each pair.
+func (fs *FlagSet) IntMap(
+	name string,
+	defaultValue map[string]int,
+	usage string,
+	extractors ...Extractor,
+) *map[string]int {
+	v := new(map[string]int)
+	fs.IntMapVar(v, name, defaultValue, usage, extractors...)
+	return v
+}
+
+// BoolMap adds a new map[string]bool flag and returns a pointer to the value
+// that will be filled once the flag set is parsed. Values are given as
+// key=value pairs, repeating the flag for each pair.
+func (fs *FlagSet) BoolMap(
+	name string,
+	defaultValue map[string]bool,
+	usage string,
+	extractors ...Extractor,
+) *map[string]bool {
+	v := new(map[string]bool)
+	fs.BoolMapVar(v, name, defaultValue, usage, extractors...)
+	return v
+}
+
 // StringVar adds a new string flag. When the flag set is parsed it will fill
 // the given pointer.
 func (fs *FlagSet) StringVar(
@@ -681,7 +2899,8 @@ func (fs *FlagSet) Uint64Var(
 }
 
 // BoolVar adds a new bool flag. When the flag set is parsed it will fill the
-// given pointer.
+// given pointer. The flag is set to true if it's given without a value, and
+// it accepts any inline value accepted by strconv.ParseBool.
 func (fs *FlagSet) BoolVar(
 	v *bool,
 	name string,
@@ -715,6 +2934,70 @@ func (fs *FlagSet) DurationVar(
 	fs.addFlag(name, defaultValue, usage, NewValue(v), extractors)
 }
 
+// TimeOfDayVar adds a new time of day flag. When the flag set is parsed it
+// will fill the given pointer with the time of day. Times of day can be given
+// as HH:MM or HH:MM:SS.
+func (fs *FlagSet) TimeOfDayVar(
+	v *TimeOfDay,
+	name string,
+	defaultValue TimeOfDay,
+	usage string,
+	extractors ...Extractor,
+) {
+	fs.addFlag(name, defaultValue, usage, NewValue(v), extractors)
+}
+
+// TimeVar adds a new time flag. When the flag set is parsed it will fill the
+// given pointer with the time. Times can be given as strings in the given
+// layout, such as time.RFC3339, which is used if the layout is empty, or as
+// seconds since the Unix epoch in the sources.
+func (fs *FlagSet) TimeVar(
+	v *time.Time,
+	name string,
+	defaultValue time.Time,
+	usage string,
+	layout string,
+	extractors ...Extractor,
+) {
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	fs.addFlag(name, defaultValue, usage, timeValue{v, layout}, extractors)
+}
+
+// RateVar adds a new rate flag. When the flag set is parsed it will fill the
+// given pointer with the rate in events per second. Rates can be given as
+// N/s, N/m or N/h.
+func (fs *FlagSet) RateVar(
+	v *float64,
+	name string,
+	defaultValue float64,
+	usage string,
+	extractors ...Extractor,
+) {
+	fs.addFlag(name, defaultValue, usage, rateValue{v}, extractors)
+}
+
+// EnumVar adds a new string flag whose value must be one of the given
+// choices. When the flag set is parsed it will fill the given pointer. If
+// ignoreCase is set, values match the choices no matter their case. It panics
+// if the default value is not one of the choices.
+func (fs *FlagSet) EnumVar(
+	v *string,
+	name string,
+	defaultValue string,
+	choices []string,
+	ignoreCase bool,
+	usage string,
+	extractors ...Extractor,
+) {
+	if err := assignEnum(&defaultValue, defaultValue, choices, ignoreCase); err != nil {
+		panic(fmt.Errorf("flag %s: invalid default value: %s", name, err))
+	}
+
+	fs.addFlag(name, defaultValue, usage, enumValue{v, choices, ignoreCase}, extractors)
+}
+
 // StringListVar adds a new []string flag. When the flag set is parsed it will
 // fill the given pointer.
 func (fs *FlagSet) StringListVar(
@@ -798,3 +3081,97 @@ func (fs *FlagSet) DurationListVar(
 ) {
 	fs.addFlag(name, defaultValue, usage, NewValue(v), extractors)
 }
+
+// IPVar adds a new net.IP flag. When the flag set is parsed it will fill the
+// given pointer.
+func (fs *FlagSet) IPVar(
+	v *net.IP,
+	name string,
+	defaultValue net.IP,
+	usage string,
+	extractors ...Extractor,
+) {
+	fs.addFlag(name, defaultValue, usage, NewValue(v), extractors)
+}
+
+// IPListVar adds a new []net.IP flag. When the flag set is parsed it will
+// fill the given pointer. Besides repeating the flag, several addresses can be
+// given separated by commas.
+func (fs *FlagSet) IPListVar(
+	v *[]net.IP,
+	name string,
+	defaultValue []net.IP,
+	usage string,
+	extractors ...Extractor,
+) {
+	fs.addFlag(name, defaultValue, usage, NewValue(v), extractors)
+}
+
+// URLVar adds a new url.URL flag. When the flag set is parsed it will fill
+// the given pointer.
+func (fs *FlagSet) URLVar(
+	v *url.URL,
+	name string,
+	defaultValue url.URL,
+	usage string,
+	extractors ...Extractor,
+) {
+	fs.addFlag(name, defaultValue, usage, NewValue(v), extractors)
+}
+
+// URLListVar adds a new []url.URL flag. When the flag set is parsed it will
+// fill the given pointer. Besides repeating the flag, several URLs can be
+// given separated by commas.
+func (fs *FlagSet) URLListVar(
+	v *[]url.URL,
+	name string,
+	defaultValue []url.URL,
+	usage string,
+	extractors ...Extractor,
+) {
+	fs.addFlag(name, defaultValue, usage, NewValue(v), extractors)
+}
+
+// TextVar adds a new flag for a type implementing encoding.TextUnmarshaler,
+// such as netip.Addr. When the flag set is parsed it will fill the given
+// value using its UnmarshalText method for the values given as strings. The
+// default value of the flag is the value it had when the flag was defined.
+func (fs *FlagSet) TextVar(
+	v encoding.TextUnmarshaler,
+	name string,
+	usage string,
+	extractors ...Extractor,
+) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		panic(fmt.Errorf("flag %s: value must be a non-nil pointer, got %T", name, v))
+	}
+
+	fs.addFlag(name, rv.Elem().Interface(), usage, textValue{v}, extractors)
+}
+
+// IntMapVar adds a new map[string]int flag. When the flag set is parsed it
+// will fill the given pointer. Values are given as key=value pairs, repeating
+// the flag for each pair.
+func (fs *FlagSet) IntMapVar(
+	v *map[string]int,
+	name string,
+	defaultValue map[string]int,
+	usage string,
+	extractors ...Extractor,
+) {
+	fs.addFlag(name, defaultValue, usage, NewValue(v), extractors)
+}
+
+// BoolMapVar adds a new map[string]bool flag. When the flag set is parsed it
+// will fill the given pointer. Values are given as key=value pairs, repeating
+// the flag for each pair.
+func (fs *FlagSet) BoolMapVar(
+	v *map[string]bool,
+	name string,
+	defaultValue map[string]bool,
+	usage string,
+	extractors ...Extractor,
+) {
+	fs.addFlag(name, defaultValue, usage, NewValue(v), extractors)
+}
@@ -2,10 +2,18 @@ package flagga
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 )
 
@@ -89,52 +97,1938 @@ func TestParseNextSingleDash(t *testing.T) {
 	expect(t, *x, 5)
 }
 
+func TestParseRepeatedFlag(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		expected int
+	}{
+		{[]string{"-x=1", "--x=2"}, 2},
+		{[]string{"--x=2", "-x=1"}, 1},
+		{[]string{"-x", "1", "--x", "2", "-x", "3"}, 3},
+	}
+
+	for _, tt := range testCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var fs FlagSet
+			x := fs.Int("x", 0, "")
+			l := fs.IntList("l", nil, "")
+
+			args := append(tt.args, "-l=1", "--l=2")
+			expect(t, fs.Parse(args), nil)
+			expect(t, *x, tt.expected)
+			expect(t, *l, []int{1, 2})
+		})
+	}
+}
+
+func TestParseLoneDash(t *testing.T) {
+	var fs FlagSet
+
+	x := fs.Int("x", 0, "")
+	err := fs.Parse([]string{"-", "-x", "5", "-"})
+
+	expect(t, err, nil)
+	expect(t, *x, 5)
+	expect(t, fs.Args(), []string{"-", "-"})
+}
+
 func TestParseNextInlineValue(t *testing.T) {
 	var fs FlagSet
 
-	x := fs.Int("x", 0, "")
-	remaining, err := fs.parseNext([]string{"-x=5"})
+	x := fs.Int("x", 0, "")
+	remaining, err := fs.parseNext([]string{"-x=5"})
+
+	expect(t, err, nil)
+	expect(t, remaining, []string{})
+	expect(t, *x, 5)
+}
+
+func TestParseEqualsInValue(t *testing.T) {
+	testCases := [][]string{
+		{"--header=Authorization=Bearer x", "-header=X-A=b=c"},
+		{"--header", "Authorization=Bearer x", "-header", "X-A=b=c"},
+	}
+
+	for _, args := range testCases {
+		t.Run(args[0], func(t *testing.T) {
+			var fs FlagSet
+			headers := fs.StringList("header", nil, "")
+
+			expect(t, fs.Parse(args), nil)
+			expect(t, *headers, []string{"Authorization=Bearer x", "X-A=b=c"})
+		})
+	}
+}
+
+func TestParseNextInvalidFlagSyntax(t *testing.T) {
+	var fs FlagSet
+
+	_, err := fs.parseNext([]string{"---x=5"})
+	expect(t, err, fmt.Errorf("invalid flag syntax: ---x=5"))
+
+	_, err = fs.parseNext([]string{"-x="})
+	expect(t, err, fmt.Errorf("invalid flag syntax: -x="))
+}
+
+func TestParseNextEmptyInlineValue(t *testing.T) {
+	var fs FlagSet
+
+	s := fs.String("s", "default", "")
+	l := fs.StringList("l", nil, "")
+	x := fs.Int("x", 0, "")
+
+	remaining, err := fs.parseNext([]string{"--s="})
+	expect(t, err, nil)
+	expect(t, remaining, []string{})
+	expect(t, *s, "")
+
+	_, err = fs.parseNext([]string{"--l="})
+	expect(t, err, nil)
+	expect(t, *l, []string{""})
+
+	_, err = fs.parseNext([]string{"--x="})
+	expect(t, err, fmt.Errorf("invalid flag syntax: --x="))
+	expect(t, *x, 0)
+}
+
+func TestIgnoreUnknown(t *testing.T) {
+	var fs FlagSet
+	fs.SetIgnoreUnknown(true)
+	x := fs.Int("x", 0, "")
+	b := fs.Bool("b", "")
+
+	err := fs.Parse([]string{
+		"--foo=bar",
+		"-x", "5",
+		"--baz", "qux",
+		"-v",
+		"-b",
+		"--empty=",
+		"--last",
+		"-b",
+		"positional",
+	})
+
+	expect(t, err, nil)
+	expect(t, *x, 5)
+	expect(t, *b, true)
+	expect(t, fs.UnknownArgs(), []string{
+		"--foo=bar",
+		"--baz", "qux",
+		"-v",
+		"--empty=",
+		"--last",
+	})
+	expect(t, fs.Args(), []string{"positional"})
+}
+
+func TestUnknownHandler(t *testing.T) {
+	var unknown [][2]string
+	var fs FlagSet
+	fs.SetIgnoreUnknown(true)
+	fs.SetUnknownHandler(func(name, value string) error {
+		unknown = append(unknown, [2]string{name, value})
+		return nil
+	})
+	x := fs.Int("x", 0, "")
+
+	err := fs.Parse([]string{
+		"--foo=bar",
+		"-x", "5",
+		"--baz", "qux",
+		"-v",
+		"--last",
+		"-b",
+		"positional",
+	})
+
+	expect(t, err, nil)
+	expect(t, *x, 5)
+	expect(t, unknown, [][2]string{
+		{"foo", "bar"},
+		{"baz", "qux"},
+		{"v", ""},
+		{"last", ""},
+		{"b", "positional"},
+	})
+	expect(t, fs.UnknownArgs(), []string(nil))
+	expect(t, fs.Args(), []string(nil))
+
+	errUnknown := fmt.Errorf("flag foo is not allowed")
+	fs2 := NewFlagSet("", "", ContinueOnError)
+	fs2.SetOutput(ioutil.Discard)
+	fs2.SetUnknownHandler(func(name, value string) error {
+		return fmt.Errorf("flag %s is not allowed", name)
+	})
+	expect(t, fs2.Parse([]string{"--foo"}), errUnknown)
+}
+
+func TestAllowInlineValues(t *testing.T) {
+	testCases := []struct {
+		allow    bool
+		args     []string
+		expected error
+	}{
+		{true, []string{"--name=foo"}, nil},
+		{true, []string{"--name", "foo"}, nil},
+		{false, []string{"--name", "foo"}, nil},
+		{false, []string{"--name=foo"}, fmt.Errorf("invalid flag syntax: --name=foo")},
+		{false, []string{"-v=true"}, fmt.Errorf("invalid flag syntax: -v=true")},
+		{false, []string{"--unknown=foo"}, fmt.Errorf("invalid flag syntax: --unknown=foo")},
+	}
+
+	for _, tt := range testCases {
+		t.Run(fmt.Sprint(tt.allow, tt.args), func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fs.SetAllowInlineValues(tt.allow)
+			name := fs.String("name", "", "")
+			fs.Bool("v", "")
+
+			err := fs.Parse(tt.args)
+			expect(t, err, tt.expected)
+			if err == nil {
+				expect(t, *name, "foo")
+			}
+		})
+	}
+}
+
+func TestBundling(t *testing.T) {
+	type result struct {
+		v, n bool
+		o    string
+		c    int
+		nv   bool
+		args []string
+	}
+
+	testCases := []struct {
+		args     []string
+		expected result
+		err      error
+	}{
+		{[]string{"-vn"}, result{v: true, n: true}, nil},
+		{[]string{"-vno=out.txt"}, result{v: true, n: true, o: "out.txt"}, nil},
+		{[]string{"-vnoout.txt"}, result{v: true, n: true, o: "out.txt"}, nil},
+		{[]string{"-vno", "out.txt", "a"}, result{v: true, n: true, o: "out.txt", args: []string{"a"}}, nil},
+		{[]string{"-vo=a=b"}, result{v: true, o: "a=b"}, nil},
+		{[]string{"-ovn"}, result{o: "vn"}, nil},
+		{[]string{"-vo="}, result{v: true}, nil},
+		{[]string{"-vc3"}, result{v: true, c: 3}, nil},
+		{[]string{"-nc=3", "-v"}, result{v: true, n: true, c: 3}, nil},
+		{[]string{"-nv"}, result{nv: true}, nil},
+		{[]string{"-vc="}, result{}, fmt.Errorf("invalid flag syntax: -vc=")},
+		{[]string{"-vo"}, result{}, fmt.Errorf("expecting value for flag: o")},
+		{[]string{"-vo", "-n"}, result{}, fmt.Errorf("expecting value for flag: o")},
+		{[]string{"-vx"}, result{}, fmt.Errorf("unknown flag vx")},
+		{[]string{"--vn"}, result{}, fmt.Errorf("unknown flag vn")},
+	}
+
+	for _, tt := range testCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fs.SetAllowBundling(true)
+			v := fs.Bool("v", "")
+			n := fs.Bool("n", "")
+			o := fs.String("o", "", "")
+			c := fs.Int("c", 0, "")
+			nv := fs.Bool("nv", "")
+
+			err := fs.Parse(tt.args)
+			expect(t, err, tt.err)
+			if err == nil {
+				expect(t, result{*v, *n, *o, *c, *nv, fs.Args()}, tt.expected)
+			}
+		})
+	}
+
+	fs := NewFlagSet("", "", ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.Bool("v", "")
+	fs.Bool("n", "")
+	expect(t, fs.Parse([]string{"-vn"}), fmt.Errorf("unknown flag vn"))
+}
+
+func TestParse(t *testing.T) {
+	var fs FlagSet
+
+	os.Setenv("TEST_B", "env_bar")
+	os.Setenv("TEST_C", "env_baz")
+
+	a := fs.String("a", "", "")
+	b := fs.String("b", "", "", Env("TEST_B"))
+	c := fs.String("c", "", "", Env("TEST_C"))
+	d := fs.String("d", "default", "", Env("TEST_D"))
+
+	expect(t, fs.Parsed(), false)
+
+	err := fs.Parse([]string{"-a=foo", "-b=bar", "foo", "bar"}, EnvPrefix(""))
+	expect(t, err, nil)
+
+	expect(t, *a, "foo")
+	expect(t, *b, "bar")
+	expect(t, *c, "env_baz")
+	expect(t, *d, "default")
+	expect(t, fs.Parsed(), true)
+	expect(t, fs.NArg(), 2)
+	expect(t, fs.Args(), []string{"foo", "bar"})
+	expect(t, fs.Arg(0), "foo")
+	expect(t, fs.NFlags(), 3)
+}
+
+func BenchmarkParseLargeArgs(b *testing.B) {
+	var args = make([]string, 0, 10000)
+	for i := 0; len(args) < 10000; i++ {
+		args = append(args, "-s", "foo", "--n=1", "-l", fmt.Sprint(i), "-b", fmt.Sprint("positional", i))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var fs FlagSet
+		fs.String("s", "", "")
+		fs.Int("n", 0, "")
+		fs.IntList("l", nil, "")
+		fs.Bool("b", "")
+		if err := fs.Parse(args); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseWithProgram(t *testing.T) {
+	var fs FlagSet
+	a := fs.String("a", "", "")
+
+	err := fs.ParseWithProgram([]string{"/usr/bin/app", "-a", "foo", "bar"})
+	expect(t, err, nil)
+	expect(t, *a, "foo")
+	expect(t, fs.Name(), "app")
+	expect(t, fs.Args(), []string{"bar"})
+
+	named := NewFlagSet("named", "", ContinueOnError)
+	expect(t, named.ParseWithProgram([]string{"app"}), nil)
+	expect(t, named.Name(), "named")
+	expect(t, named.NArg(), 0)
+}
+
+func TestParseLayered(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "flagga-layered-*.json")
+	if err != nil {
+		t.Fatalf("unexpected error creating config file: %s", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`{
+		"layered-a": "file_a",
+		"layered-b": "file_b",
+		"layered-c": "file_c",
+		"layered-e": "file_e"
+	}`)
+	if err != nil {
+		t.Fatalf("unexpected error writing config file: %s", err)
+	}
+	f.Close()
+
+	os.Setenv("LAYERED_A", "env_a")
+	os.Setenv("LAYERED_B", "env_b")
+	os.Setenv("LAYERED_E", "env_e")
+	defer os.Unsetenv("LAYERED_A")
+	defer os.Unsetenv("LAYERED_B")
+	defer os.Unsetenv("LAYERED_E")
+
+	var fs FlagSet
+	a := fs.String("layered-a", "default_a", "")
+	b := fs.String("layered-b", "default_b", "")
+	c := fs.String("layered-c", "default_c", "")
+	d := fs.String("layered-d", "default_d", "")
+	e := fs.String("layered-e", "default_e", "", JSON("layered-e"))
+
+	err = fs.ParseLayered([]string{"-layered-a=args_a"}, f.Name())
+	expect(t, err, nil)
+	expect(t, *a, "args_a")
+	expect(t, *b, "env_b")
+	expect(t, *c, "file_c")
+	expect(t, *d, "default_d")
+	expect(t, *e, "file_e")
+}
+
+func TestEnvName(t *testing.T) {
+	testCases := []struct {
+		prefix   string
+		name     string
+		expected string
+	}{
+		{"", "port", "PORT"},
+		{"", "log-level", "LOG_LEVEL"},
+		{"", "db.host", "DB_HOST"},
+		{"APP_", "log-level", "APP_LOG_LEVEL"},
+		{"APP_", "Max-Conns", "APP_MAX_CONNS"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.prefix+tt.name, func(t *testing.T) {
+			var fs FlagSet
+			fs.SetEnvPrefix(tt.prefix)
+			expect(t, fs.EnvName(tt.name), tt.expected)
+		})
+	}
+}
+
+func TestSubCommandEnvName(t *testing.T) {
+	os.Setenv("TOOL_DB_MIGRATE_STEPS", "5")
+	os.Setenv("TOOL_DB_DRY_RUN", "true")
+	defer os.Unsetenv("TOOL_DB_MIGRATE_STEPS")
+	defer os.Unsetenv("TOOL_DB_DRY_RUN")
+
+	var fs FlagSet
+	fs.SetEnvPrefix("TOOL_")
+	db := fs.SubCommand("db", "")
+	dryRun := db.Bool("dry-run", "")
+	db.AutoEnv()
+	migrate := db.SubCommand("migrate", "")
+	steps := migrate.Int("steps", 1, "")
+	migrate.AutoEnv()
+	backup := db.SubCommand("backup-now", "")
+	backup.SetEnvPrefix("BACKUP_")
+
+	expect(t, fs.EnvName("log-level"), "TOOL_LOG_LEVEL")
+	expect(t, db.EnvName("dry-run"), "TOOL_DB_DRY_RUN")
+	expect(t, migrate.EnvName("steps"), "TOOL_DB_MIGRATE_STEPS")
+	expect(t, backup.EnvName("dir"), "BACKUP_DIR")
+
+	var other FlagSet
+	expect(t, other.SubCommand("backup-now", "").EnvName("dir"), "BACKUP_NOW_DIR")
+
+	expect(t, fs.Parse([]string{"db", "migrate"}, EnvPrefix("")), nil)
+	expect(t, *dryRun, true)
+	expect(t, *steps, 5)
+}
+
+func TestAutoEnv(t *testing.T) {
+	os.Setenv("AUTOENV_LOG_LEVEL", "debug")
+	os.Setenv("AUTOENV_PORT", "9090")
+	os.Setenv("CUSTOM_HOST", "example.com")
+	defer os.Unsetenv("AUTOENV_LOG_LEVEL")
+	defer os.Unsetenv("AUTOENV_PORT")
+	defer os.Unsetenv("CUSTOM_HOST")
+
+	var fs FlagSet
+	fs.SetEnvPrefix("AUTOENV_")
+	level := fs.String("log-level", "info", "")
+	port := fs.Int("port", 8080, "", JSON("port"))
+	host := fs.String("host", "localhost", "", Env("CUSTOM_HOST"))
+	fs.AutoEnv()
+
+	expect(t, fs.Lookup("host").Extractors, []Extractor{Env("CUSTOM_HOST")})
+	expect(t, fs.Parse(nil, EnvPrefix("")), nil)
+	expect(t, *level, "debug")
+	expect(t, *port, 9090)
+	expect(t, *host, "example.com")
+}
+
+func TestWithKeyPrefix(t *testing.T) {
+	os.Setenv("HOST", "env.example.com")
+	defer os.Unsetenv("HOST")
+
+	var fs FlagSet
+	name := fs.String("name", "", "", JSON("name"))
+	var host, user *string
+	var port *int
+	fs.WithKeyPrefix("db.", func(g *FlagSet) {
+		host = g.String("db-host", "", "", JSON("host"), Env("HOST"))
+		port = g.Int("db-port", 0, "", Config("port"))
+		g.WithKeyPrefix("auth.", func(g *FlagSet) {
+			user = g.String("db-user", "", "", JSON("user"))
+		})
+	})
+	other := fs.String("other", "", "", JSON("other"))
+
+	expect(t, fs.Lookup("db-host").Extractors, []Extractor{JSON("db.host"), Env("HOST")})
+	expect(t, fs.Lookup("other").Extractors, []Extractor{JSON("other")})
+
+	content := `{
+		"name": "app",
+		"other": "foo",
+		"db": {"host": "db.example.com", "port": 5432, "auth": {"user": "admin"}}
+	}`
+	f, err := ioutil.TempFile(os.TempDir(), "flagga-prefix-*.json")
+	if err != nil {
+		t.Fatalf("unexpected error creating config file: %s", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("unexpected error writing config file: %s", err)
+	}
+	f.Close()
+
+	expect(t, fs.Parse(nil, EnvPrefix(""), JSONVia(f.Name())), nil)
+	expect(t, *name, "app")
+	expect(t, *host, "db.example.com")
+	expect(t, *port, 5432)
+	expect(t, *user, "admin")
+	expect(t, *other, "foo")
+}
+
+func TestParseLayeredUnsupportedFormat(t *testing.T) {
+	var fs FlagSet
+	err := fs.ParseLayered(nil, "config.ini")
+	expect(t, err, fmt.Errorf("unsupported config file format: config.ini"))
+}
+
+func TestParseInto(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "flagga-into-*.json")
+	if err != nil {
+		t.Fatalf("unexpected error creating config file: %s", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`{"port": 8080, "tags": ["a", "b"]}`)
+	if err != nil {
+		t.Fatalf("unexpected error writing config file: %s", err)
+	}
+	f.Close()
+
+	var fs FlagSet
+	defined := fs.String("defined", "", "")
+
+	m := map[string]interface{}{
+		"defined": "",
+		"host":    "localhost",
+		"port":    80,
+		"verbose": false,
+		"timeout": 5 * time.Second,
+		"tags":    []string(nil),
+	}
+
+	err = fs.ParseInto(
+		m,
+		[]string{"-defined=foo", "-verbose", "-timeout=1m"},
+		JSONVia(f.Name()),
+	)
+	expect(t, err, nil)
+	expect(t, *defined, "foo")
+	expect(t, m, map[string]interface{}{
+		"defined": "foo",
+		"host":    "localhost",
+		"port":    8080,
+		"verbose": true,
+		"timeout": time.Minute,
+		"tags":    []string{"a", "b"},
+	})
+}
+
+func TestParseIntoUnsupportedType(t *testing.T) {
+	var fs FlagSet
+	err := fs.ParseInto(map[string]interface{}{"x": int8(1)}, nil)
+	expect(t, err, fmt.Errorf("unsupported type int8 for flag x"))
+}
+
+func TestSubCommand(t *testing.T) {
+	os.Setenv("SUBCOMMAND_B", "env_b")
+	defer os.Unsetenv("SUBCOMMAND_B")
+
+	fs := NewFlagSet("", "", ContinueOnError)
+	a := fs.String("a", "", "")
+	cmd := fs.SubCommand("run", "runs something")
+	fs.SubCommand("stop", "stops something")
+	b := cmd.String("b", "", "", Env("SUBCOMMAND_B"))
+	c := cmd.Bool("c", "")
+
+	err := fs.Parse([]string{"-a=foo", "run", "-c", "bar"}, EnvPrefix(""))
+	expect(t, err, nil)
+	expect(t, fs.Command(), cmd)
+	expect(t, fs.Args(), []string(nil))
+	expect(t, *a, "foo")
+	expect(t, *b, "env_b")
+	expect(t, *c, true)
+	expect(t, cmd.Args(), []string{"bar"})
+	expect(t, cmd.Parsed(), true)
+}
+
+func TestParseGlobals(t *testing.T) {
+	testCases := []struct {
+		args    []string
+		verbose bool
+		config  string
+		command string
+		rest    []string
+		err     error
+	}{
+		{[]string{"-v", "-config", "a.json", "commit", "-m", "msg", "-v"}, true, "a.json", "commit", []string{"-m", "msg", "-v"}, nil},
+		{[]string{"commit", "-v"}, false, "", "commit", []string{"-v"}, nil},
+		{[]string{"-v", "--", "-commit", "x"}, true, "", "-commit", []string{"x"}, nil},
+		{[]string{"-v"}, true, "", "", nil, nil},
+		{[]string{"-m", "msg", "commit"}, false, "", "", nil, fmt.Errorf("unknown flag m")},
+	}
+
+	for _, tt := range testCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			verbose := fs.Bool("v", "")
+			config := fs.String("config", "", "")
+
+			command, rest, err := fs.ParseGlobals(tt.args)
+			expect(t, err, tt.err)
+			expect(t, command, tt.command)
+			expect(t, rest, tt.rest)
+			expect(t, *verbose, tt.verbose)
+			expect(t, *config, tt.config)
+			expect(t, fs.Args(), []string(nil))
+			expect(t, fs.stopAtCommand, false)
+		})
+	}
+}
+
+func TestSubCommands(t *testing.T) {
+	var fs FlagSet
+	expect(t, fs.SubCommands(), []*FlagSet{})
+	expect(t, fs.Parent(), (*FlagSet)(nil))
+
+	stop := fs.SubCommand("stop", "")
+	run := fs.SubCommand("run", "")
+	now := run.SubCommand("now", "")
+
+	expect(t, fs.SubCommands(), []*FlagSet{stop, run})
+	expect(t, run.SubCommands(), []*FlagSet{now})
+	expect(t, stop.Parent(), &fs)
+	expect(t, run.Parent(), &fs)
+	expect(t, now.Parent(), run)
+}
+
+func TestRequireSubcommand(t *testing.T) {
+	testCases := []struct {
+		name     string
+		require  bool
+		args     []string
+		expected error
+	}{
+		{"valid", true, []string{"run"}, nil},
+		{"unknown", true, []string{"foo"}, fmt.Errorf("unknown subcommand foo")},
+		{"missing", true, []string{"-a"}, fmt.Errorf("missing subcommand")},
+		{"not required", false, []string{"foo"}, nil},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fs.SetRequireSubcommand(tt.require)
+			fs.Bool("a", "")
+			fs.SubCommand("run", "")
+
+			expect(t, fs.Parse(tt.args), tt.expected)
+		})
+	}
+}
+
+func TestSubCommandAbbrev(t *testing.T) {
+	testCases := []struct {
+		name     string
+		abbrev   bool
+		args     []string
+		command  string
+		expected error
+	}{
+		{"exact", true, []string{"migrate"}, "migrate", nil},
+		{"exact prefix of another", true, []string{"mig"}, "mig", nil},
+		{"unique prefix", true, []string{"migr"}, "migrate", nil},
+		{"ambiguous prefix", true, []string{"m"}, "", fmt.Errorf("ambiguous subcommand m, could be: migrate, mig, merge")},
+		{"no match", true, []string{"run"}, "", nil},
+		{"disabled", false, []string{"migr"}, "", nil},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fs.SetAllowAbbrev(tt.abbrev)
+			fs.SubCommand("migrate", "")
+			fs.SubCommand("mig", "")
+			fs.SubCommand("merge", "")
+
+			expect(t, fs.Parse(tt.args), tt.expected)
+			if tt.command == "" {
+				expect(t, fs.Command(), (*FlagSet)(nil))
+			} else {
+				expect(t, fs.Command().Name(), tt.command)
+			}
+		})
+	}
+}
+
+func TestNormalizeFunc(t *testing.T) {
+	testCases := [][]string{
+		{"--log-level=debug"},
+		{"--log_level=debug"},
+		{"-log_level", "debug"},
+	}
+
+	for _, args := range testCases {
+		t.Run(args[0], func(t *testing.T) {
+			var fs FlagSet
+			fs.SetNormalizeFunc(func(name string) string {
+				return strings.Replace(name, "_", "-", -1)
+			})
+			level := fs.String("log_level", "info", "")
+
+			expect(t, fs.Parse(args), nil)
+			expect(t, *level, "debug")
+			expect(t, fs.Lookup("log-level").Name, "log-level")
+		})
+	}
+}
+
+func TestAliasValue(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"--enable-feature", "x"}, []string{"x"}},
+		{[]string{"--enable-all"}, []string{"a", "b", "c"}},
+		{[]string{"--enable-feature", "x", "--enable-all"}, []string{"x", "a", "b", "c"}},
+		{[]string{"--enable-all=false"}, []string{"default"}},
+		{nil, []string{"default"}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var fs FlagSet
+			features := fs.StringList("enable-feature", []string{"default"}, "")
+			fs.AliasValue("enable-all", "enable-feature", "a", "b", "c")
+
+			expect(t, fs.Parse(tt.args), nil)
+			expect(t, *features, tt.expected)
+		})
+	}
+
+	var fs FlagSet
+	fs.String("s", "", "")
+	for _, target := range []string{"s", "undefined"} {
+		func() {
+			defer func() {
+				expect(t, recover() != nil, true)
+			}()
+			fs.AliasValue("alias", target, "x")
+		}()
+	}
+}
+
+func TestRedefine(t *testing.T) {
+	t.Run("panic", func(t *testing.T) {
+		var fs FlagSet
+		fs.String("a", "", "")
+		defer func() {
+			expect(t, recover(), fmt.Errorf("flag a was already defined"))
+		}()
+		fs.Int("a", 0, "")
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		var fs FlagSet
+		fs.SetAllowRedefine(true)
+		a := fs.String("a", "", "first")
+		fs.String("b", "", "")
+		b := fs.Int("a", 1, "second")
+
+		expect(t, fs.Parse([]string{"-a", "2"}), nil)
+		expect(t, *a, "")
+		expect(t, *b, 2)
+		expect(t, fs.Lookup("a").Usage, "second")
+		expect(t, fs.flagOrder, []string{"a", "b"})
+	})
+
+	t.Run("disallow", func(t *testing.T) {
+		var fs FlagSet
+		fs.SetAllowRedefine(true)
+		fs.SetAllowRedefine(false)
+		fs.String("a", "", "")
+		defer func() {
+			expect(t, recover(), fmt.Errorf("flag a was already defined"))
+		}()
+		fs.Int("a", 0, "")
+	})
+}
+
+func TestFreeze(t *testing.T) {
+	var fs FlagSet
+	a := fs.String("a", "", "")
+	fs.Freeze()
+
+	func() {
+		defer func() {
+			expect(t, recover(), fmt.Errorf("flag b defined after the flag set was frozen"))
+		}()
+		fs.Int("b", 0, "")
+	}()
+
+	expect(t, fs.Parse([]string{"-a", "foo"}), nil)
+	expect(t, *a, "foo")
+	expect(t, fs.Lookup("b"), (*Flag)(nil))
+}
+
+func TestAppendArgs(t *testing.T) {
+	config := `{"x": ["c", "a"], "n": [3, 1]}`
+	testCases := []struct {
+		name     string
+		args     []string
+		append   bool
+		expected []string
+		n        []int
+		y        []string
+	}{
+		{"sources only", nil, true, []string{"c", "a"}, []int{3, 1}, nil},
+		{"args replace sources", []string{"-x", "b", "-n=2", "-x=d"}, false, []string{"b", "d"}, []int{2}, nil},
+		{"args after sources", []string{"-x", "b", "-n=2", "-x=d"}, true, []string{"c", "a", "b", "d"}, []int{3, 1, 2}, nil},
+		{"args not in sources", []string{"-y", "b", "-y", "a"}, true, []string{"c", "a"}, []int{3, 1}, []string{"b", "a"}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var fs FlagSet
+			x := fs.StringList("x", []string{"default"}, "", Config("x"))
+			y := fs.StringList("y", nil, "", Config("y"))
+			n := fs.IntList("n", nil, "", Config("n"))
+			for _, name := range []string{"x", "y", "n"} {
+				fs.Lookup(name).AppendArgs = tt.append
+			}
+
+			source := ReaderSource(strings.NewReader(config), json.Unmarshal)
+			expect(t, fs.Parse(tt.args, source), nil)
+			expect(t, *x, tt.expected)
+			expect(t, *n, tt.n)
+			expect(t, *y, tt.y)
+		})
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	os.Setenv("AUDIT_PORT", "9090")
+	defer os.Unsetenv("AUDIT_PORT")
+
+	before := time.Now()
+	var fs FlagSet
+	fs.String("host", "localhost", "")
+	fs.Int("port", 8080, "", Env("AUDIT_PORT"))
+	fs.StringList("tag", nil, "")
+	fs.Bool("v", "")
+	expect(t, fs.AuditLog(), []AuditEntry{})
+
+	err := fs.Parse([]string{"--tag", "a", "-v", "--tag=b"}, EnvPrefix(""))
+	expect(t, err, nil)
+
+	log := fs.AuditLog()
+	var entries = make([]AuditEntry, len(log))
+	for i, e := range log {
+		if e.Time.Before(before) || (i > 0 && e.Time.Before(log[i-1].Time)) {
+			t.Errorf("unexpected time of entry %d: %s", i, e.Time)
+		}
+		entries[i] = AuditEntry{Flag: e.Flag, Value: e.Value, Origin: e.Origin}
+	}
+
+	expect(t, entries[:3], []AuditEntry{
+		{Flag: "tag", Value: []string{"a"}, Origin: OriginArgs},
+		{Flag: "v", Value: true, Origin: OriginArgs},
+		{Flag: "tag", Value: []string{"a", "b"}, Origin: OriginArgs},
+	})
+
+	// the flags not given in the arguments are filled in the order they
+	// were defined
+	expect(t, entries[3:], []AuditEntry{
+		{Flag: "host", Value: "localhost", Origin: OriginDefault},
+		{Flag: "port", Value: 9090, Origin: OriginSource},
+	})
+
+	expect(t, fs.ApplyArg("--host=example.com"), nil)
+	log = fs.AuditLog()
+	expect(t, log[len(log)-1].Flag, "host")
+	expect(t, log[len(log)-1].Value, "example.com")
+	expect(t, log[len(log)-1].Origin, OriginArgs)
+}
+
+func TestAuditLogOrder(t *testing.T) {
+	var fs FlagSet
+	names := []string{"zeta", "alpha", "mu", "beta", "omega", "gamma", "kappa", "delta"}
+	for _, name := range names {
+		fs.String(name, name, "")
+	}
+	expect(t, fs.Parse(nil), nil)
+
+	var flags []string
+	for _, e := range fs.AuditLog() {
+		flags = append(flags, e.Flag)
+	}
+	expect(t, flags, names)
+}
+
+func TestParseResult(t *testing.T) {
+	os.Setenv("PARSE_RESULT_PORT", "9090")
+	defer os.Unsetenv("PARSE_RESULT_PORT")
+
+	var fs FlagSet
+	fs.SetIgnoreUnknown(true)
+	fs.String("host", "", "")
+	fs.Int("port", 8080, "", Env("PARSE_RESULT_PORT"))
+	fs.Bool("verbose", "")
+	fs.Bool("debug", "")
+
+	result, err := fs.ParseResult(
+		[]string{"-host", "localhost", "--unknown=foo", "-debug", "a", "b"},
+		EnvPrefix(""),
+	)
+	expect(t, err, nil)
+	expect(t, result, &ParseResult{
+		Args:        []string{"a", "b"},
+		Found:       []string{"host", "port", "debug"},
+		UnknownArgs: []string{"--unknown=foo"},
+		Origins: map[string]Origin{
+			"host":    OriginArgs,
+			"port":    OriginSource,
+			"verbose": OriginDefault,
+			"debug":   OriginArgs,
+		},
+	})
+
+	fs2 := NewFlagSet("", "", ContinueOnError)
+	fs2.SetOutput(ioutil.Discard)
+	result, err = fs2.ParseResult([]string{"-foo"})
+	expect(t, err, fmt.Errorf("unknown flag foo"))
+	expect(t, result, (*ParseResult)(nil))
+}
+
+func TestApplyArg(t *testing.T) {
+	fs := NewFlagSet("", "", ContinueOnError)
+	fs.SetIgnoreUnknown(true)
+	host := fs.String("host", "localhost", "")
+	port := fs.Int("port", 8080, "")
+	verbose := fs.Bool("verbose", "")
+	tags := fs.StringList("tags", []string{"a"}, "")
+
+	expect(t, fs.Parse(nil), nil)
+	expect(t, fs.ApplyArg("--host=example.com"), nil)
+	expect(t, fs.ApplyArg("-port=9090"), nil)
+	expect(t, fs.ApplyArg("--verbose"), nil)
+	expect(t, fs.ApplyArg("--tags=b"), nil)
+
+	expect(t, *host, "example.com")
+	expect(t, *port, 9090)
+	expect(t, *verbose, true)
+	expect(t, *tags, []string{"b"})
+
+	expect(t, fs.ApplyArg("--tags=c"), nil)
+	expect(t, *tags, []string{"b", "c"})
+
+	expect(t, fs.ApplyArg("--port"), fmt.Errorf("expecting value for flag: port"))
+	expect(t, fs.ApplyArg("--port=foo") != nil, true)
+	expect(t, fs.ApplyArg("--unknown=foo"), fmt.Errorf("unknown flag unknown"))
+	expect(t, fs.ApplyArg("host=foo"), fmt.Errorf("invalid flag syntax: host=foo"))
+	expect(t, fs.ApplyArg("--"), fmt.Errorf("invalid flag syntax: --"))
+	expect(t, fs.UnknownArgs(), []string(nil))
+	expect(t, fs.Args(), []string(nil))
+}
+
+func TestApplyArgList(t *testing.T) {
+	fs := NewFlagSet("", "", ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	tags := fs.StringList("tags", []string{"a"}, "")
+	hosts := fs.StringList("hosts", []string{"localhost"}, "", Config("hosts"))
+
+	err := fs.Parse(
+		[]string{"--tags=b"},
+		ReaderSource(strings.NewReader(`{"hosts": ["a.example.com"]}`), json.Unmarshal),
+	)
+	expect(t, err, nil)
+	expect(t, *hosts, []string{"a.example.com"})
+
+	expect(t, fs.ApplyArg("--tags=c"), nil)
+	expect(t, fs.ApplyArg("--hosts=b.example.com"), nil)
+	expect(t, *tags, []string{"b", "c"})
+	expect(t, *hosts, []string{"b.example.com"})
+}
+
+func TestClearOnEmpty(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		expected []string
+	}{
+		{nil, []string{"a", "b"}},
+		{[]string{"-x="}, nil},
+		{[]string{"-x", ""}, nil},
+		{[]string{"-x=", "-x=c"}, []string{"c"}},
+		{[]string{"-x=c", "-x=", "-x", "d", "-x=e"}, []string{"d", "e"}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var fs FlagSet
+			x := fs.StringList("x", []string{"a", "b"}, "")
+			fs.Lookup("x").ClearOnEmpty = true
+
+			expect(t, fs.Parse(tt.args), nil)
+			expect(t, *x, tt.expected)
+		})
+	}
+
+	var fs FlagSet
+	n := fs.IntList("n", []int{1, 2}, "")
+	fs.Lookup("n").ClearOnEmpty = true
+	expect(t, fs.Parse([]string{"-n=", "-n=3"}), nil)
+	expect(t, *n, []int{3})
+
+	fs2 := NewFlagSet("", "", ContinueOnError)
+	fs2.SetOutput(ioutil.Discard)
+	fs2.IntList("n", []int{1}, "")
+	expect(t, fs2.Parse([]string{"-n="}), fmt.Errorf("invalid flag syntax: -n="))
+}
+
+func TestDeprecatedAlias(t *testing.T) {
+	var buf bytes.Buffer
+	var fs FlagSet
+	fs.SetOutput(&buf)
+	host := fs.String("new-host", "", "")
+	tags := fs.StringList("tag", nil, "")
+	fs.DeprecatedAlias("old-host", "new-host")
+	fs.DeprecatedAlias("tags", "tag")
+
+	err := fs.Parse([]string{"--old-host=example.com", "-tags", "a", "--tags=b", "-tag", "c"})
+	expect(t, err, nil)
+	expect(t, *host, "example.com")
+	expect(t, *tags, []string{"a", "b", "c"})
+	expect(t, buf.String(), "flag old-host is deprecated, use new-host instead\n"+
+		"flag tags is deprecated, use tag instead\n")
+}
+
+func TestWarnAdjacentPositional(t *testing.T) {
+	testCases := []struct {
+		warn     bool
+		args     []string
+		expected string
+	}{
+		{true, []string{"--name=john", "doe"}, "flag name was given the value \"john\" followed by the argument \"doe\", quote the value if they are meant to be a single value\n"},
+		{true, []string{"--name=john doe"}, ""},
+		{true, []string{"--name", "john", "doe"}, ""},
+		{true, []string{"--name=john", "--port=80"}, ""},
+		{true, []string{"--name=john", "-", "doe"}, ""},
+		{true, []string{"-v=true", "doe"}, ""},
+		{true, []string{"--name=john", "run"}, ""},
+		{true, []string{"--port=80", "doe"}, "flag port was given the value \"80\" followed by the argument \"doe\", quote the value if they are meant to be a single value\n"},
+		{false, []string{"--name=john", "doe"}, ""},
+	}
+
+	for _, tt := range testCases {
+		t.Run(fmt.Sprint(tt.warn, tt.args), func(t *testing.T) {
+			var buf bytes.Buffer
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(&buf)
+			fs.SetWarnAdjacentPositional(tt.warn)
+			fs.String("name", "", "")
+			fs.Int("port", 0, "")
+			fs.Bool("v", "")
+			fs.SubCommand("run", "")
+
+			expect(t, fs.Parse(tt.args), nil)
+			expect(t, buf.String(), tt.expected)
+		})
+	}
+}
+
+func TestArgIndex(t *testing.T) {
+	var fs FlagSet
+	fs.String("host", "", "")
+	fs.Int("port", 0, "")
+	fs.Bool("v", "")
+	fs.Bool("x", "")
+	fs.String("unused", "", "")
+	fs.SetAllowBundling(true)
+	fs.Positional("src", "")
+	fs.Variadic("rest", 0, "")
+	fs.Positional("dst", "")
+
+	err := fs.Parse([]string{
+		"--host", "localhost",
+		"a",
+		"-port=80",
+		"-vx",
+		"b",
+		"--host=example.com",
+		"--", "c", "-d",
+	})
+	expect(t, err, nil)
+
+	testCases := []struct {
+		name     string
+		expected int
+	}{
+		{"host", 6},
+		{"port", 3},
+		{"v", 4},
+		{"x", 4},
+		{"unused", -1},
+		{"src", 2},
+		{"rest", 5},
+		{"dst", 9},
+		{"undefined", -1},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, fs.ArgIndex(tt.name), tt.expected)
+		})
+	}
+
+	expect(t, fs.ApplyArg("-port=90"), nil)
+	expect(t, fs.ArgIndex("port"), 3)
+}
+
+func TestRequired(t *testing.T) {
+	os.Setenv("REQUIRED_B", "env_b")
+	defer os.Unsetenv("REQUIRED_B")
+
+	testCases := []struct {
+		args     []string
+		expected error
+	}{
+		{[]string{"-a", "foo", "-c", "bar"}, nil},
+		{[]string{"-a", "foo"}, fmt.Errorf("missing required flags: c")},
+		{nil, fmt.Errorf("missing required flags: a, c")},
+	}
+
+	for _, tt := range testCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fs.String("a", "", "")
+			fs.String("b", "", "", Env("REQUIRED_B"))
+			fs.String("c", "default", "")
+			fs.String("d", "", "")
+			for _, name := range []string{"a", "b", "c"} {
+				fs.Lookup(name).Required = true
+			}
+
+			expect(t, fs.Parse(tt.args, EnvPrefix("")), tt.expected)
+		})
+	}
+}
+
+func TestAllOrNone(t *testing.T) {
+	os.Setenv("ALL_OR_NONE_KEY", "key.pem")
+	defer os.Unsetenv("ALL_OR_NONE_KEY")
+
+	errPartial := fmt.Errorf("flags tls-cert, tls-key must be given all together or not at all")
+	testCases := []struct {
+		args     []string
+		sources  []Source
+		expected error
+	}{
+		{nil, nil, nil},
+		{[]string{"-tls-cert", "cert.pem", "-tls-key", "key.pem"}, nil, nil},
+		{[]string{"-tls-cert", "cert.pem"}, []Source{EnvPrefix("ALL_OR_NONE_")}, nil},
+		{[]string{"-tls-cert", "cert.pem"}, nil, errPartial},
+		{[]string{"-tls-key", "key.pem", "-port", "80"}, nil, errPartial},
+		{nil, []Source{EnvPrefix("ALL_OR_NONE_")}, errPartial},
+	}
+
+	for _, tt := range testCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fs.String("tls-cert", "", "")
+			fs.String("tls-key", "default.pem", "", Env("KEY"))
+			fs.Int("port", 8080, "")
+			fs.AllOrNone("tls-cert", "tls-key")
+
+			expect(t, fs.Parse(tt.args, tt.sources...), tt.expected)
+		})
+	}
+}
+
+func TestRequireOverride(t *testing.T) {
+	errDefault := fmt.Errorf("flag password must be set to a value other than its default CHANGEME")
+	testCases := []struct {
+		args     []string
+		expected error
+	}{
+		{nil, errDefault},
+		{[]string{"-password", "CHANGEME"}, errDefault},
+		{[]string{"-password", "s3cr3t"}, nil},
+	}
+
+	for _, tt := range testCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fs.String("password", "CHANGEME", "")
+			fs.Int("port", 8080, "")
+			fs.RequireOverride("password")
+
+			expect(t, fs.Parse(tt.args), tt.expected)
+		})
+	}
+}
+
+func TestAddCrossValidator(t *testing.T) {
+	errRange := fmt.Errorf("max must be greater than or equal to min")
+	testCases := []struct {
+		args     []string
+		expected error
+	}{
+		{nil, nil},
+		{[]string{"-min", "5", "-max", "5"}, nil},
+		{[]string{"-max", "20"}, nil},
+		{[]string{"-min", "11"}, errRange},
+		{[]string{"-min", "5", "-max", "2"}, errRange},
+	}
+
+	for _, tt := range testCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fs.Int("min", 1, "")
+			fs.Int("max", 10, "")
+			var calls int
+			fs.AddCrossValidator(func(fs *FlagSet) error {
+				calls++
+				min := fs.Lookup("min").Value.(Getter).Get().(int)
+				max := fs.Lookup("max").Value.(Getter).Get().(int)
+				if max < min {
+					return errRange
+				}
+				return nil
+			})
+			fs.AddCrossValidator(func(*FlagSet) error {
+				calls++
+				return nil
+			})
+
+			expect(t, fs.Parse(tt.args), tt.expected)
+			if tt.expected == nil {
+				expect(t, calls, 2)
+			} else {
+				expect(t, calls, 1)
+			}
+		})
+	}
+}
+
+func TestSplitOn(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "flagga-split")
+	if err != nil {
+		t.Fatalf("unexpected error creating dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := "a.example.com\nb.example.com\r\n\n  c.example.com\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "hosts"), []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+
+	os.Setenv("SPLIT_TAGS", "a, b,,c ")
+	os.Setenv("SPLIT_PORTS", "80,\n443")
+	os.Setenv("SPLIT_OTHER", "a,b")
+	defer os.Unsetenv("SPLIT_TAGS")
+	defer os.Unsetenv("SPLIT_PORTS")
+	defer os.Unsetenv("SPLIT_OTHER")
+
+	var fs FlagSet
+	hosts := fs.StringList("hosts", nil, "", Config("hosts"))
+	tags := fs.StringList("tags", nil, "", Env("TAGS"))
+	ports := fs.IntList("ports", nil, "", Env("PORTS"))
+	other := fs.StringList("other", nil, "", Env("OTHER"))
+	name := fs.String("name", "", "", Env("OTHER"))
+	for _, n := range []string{"hosts", "tags", "ports", "name"} {
+		fs.Lookup(n).SplitOn = []string{",", "\n"}
+	}
+
+	err = fs.Parse([]string{"--tags", "d,e"}, EnvPrefix("SPLIT_"), ConfigTreeVia(dir))
+	expect(t, err, nil)
+	expect(t, *hosts, []string{"a.example.com", "b.example.com", "c.example.com"})
+	expect(t, *tags, []string{"d,e"})
+	expect(t, *ports, []int{80, 443})
+	expect(t, *other, []string{"a,b"})
+	expect(t, *name, "a,b")
+
+	fs = FlagSet{}
+	tags = fs.StringList("tags", nil, "", Env("TAGS"))
+	fs.Lookup("tags").SplitOn = []string{","}
+	expect(t, fs.Parse(nil, EnvPrefix("SPLIT_")), nil)
+	expect(t, *tags, []string{"a", "b", "c"})
+}
+
+func TestFlagOrPositional(t *testing.T) {
+	testCases := []struct {
+		name string
+		args []string
+		file string
+		err  error
+	}{
+		{"flag only", []string{"--file", "a.txt"}, "a.txt", nil},
+		{"positional only", []string{"b.txt"}, "b.txt", nil},
+		{"both", []string{"--file", "a.txt", "b.txt"}, "", fmt.Errorf("flag file can't be given along with argument 0")},
+		{"neither", nil, "default.txt", nil},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			file := fs.String("file", "default.txt", "")
+			fs.FlagOrPositional("file", 0)
+
+			err := fs.Parse(tt.args)
+			expect(t, err, tt.err)
+			if err == nil {
+				expect(t, *file, tt.file)
+			}
+		})
+	}
+}
+
+func TestConfigFlag(t *testing.T) {
+	var files []string
+	for _, content := range []string{
+		`{"host": "localhost", "port": 8080}`,
+		`{"port": 9090, "name": "b"}`,
+	} {
+		f, err := ioutil.TempFile(os.TempDir(), "flagga-config-*.json")
+		if err != nil {
+			t.Fatalf("unexpected error creating file: %s", err)
+		}
+		defer os.Remove(f.Name())
+
+		if _, err := f.WriteString(content); err != nil {
+			t.Fatalf("unexpected error writing file: %s", err)
+		}
+		f.Close()
+		files = append(files, f.Name())
+	}
+
+	os.Setenv("CONFIG_FLAG_NAME", "env")
+	defer os.Unsetenv("CONFIG_FLAG_NAME")
+
+	var fs FlagSet
+	configs := fs.StringList("config", nil, "")
+	host := fs.String("host", "", "", JSON("host"))
+	port := fs.Int("port", 0, "", Config("port"))
+	name := fs.String("name", "", "", Env("CONFIG_FLAG_NAME"), JSON("name"))
+	fs.ConfigFlag("config")
+
+	err := fs.Parse([]string{"--config", files[0], "--config", files[1]}, EnvPrefix(""))
+	expect(t, err, nil)
+	expect(t, *configs, files)
+	expect(t, *host, "localhost")
+	expect(t, *port, 9090)
+	expect(t, *name, "env")
+}
+
+func TestConfigFlagUnsupported(t *testing.T) {
+	fs := NewFlagSet("", "", ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.StringList("config", nil, "")
+	fs.ConfigFlag("config")
+
+	err := fs.Parse([]string{"--config", "config.ini"})
+	expect(t, err, fmt.Errorf("unsupported config file format: config.ini"))
+
+	fs = NewFlagSet("", "", ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.StringList("config", []string{"config.ini"}, "")
+	fs.ConfigFlag("config")
+	expect(t, fs.Parse(nil), fmt.Errorf("unsupported config file format: config.ini"))
+}
+
+func TestConfigFlagDefault(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "flagga-config-*.json")
+	if err != nil {
+		t.Fatalf("unexpected error creating file: %s", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(`{"port": 8080}`); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+	f.Close()
+
+	var fs FlagSet
+	fs.StringList("config", []string{f.Name(), f.Name() + ".missing.json"}, "")
+	port := fs.Int("port", 0, "", JSON("port"))
+	fs.ConfigFlag("config")
+
+	expect(t, fs.Parse(nil), nil)
+	expect(t, *port, 8080)
+}
+
+func TestConfigFlagYAML(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "flagga-config")
+	if err != nil {
+		t.Fatalf("unexpected error creating dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "a.yaml")
+	if err := ioutil.WriteFile(file, []byte("host: localhost\nport: 8080"), 0644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+
+	var fs FlagSet
+	fs.SetDecoder(YAMLKind, unmarshalTestYAML)
+	fs.StringList("config", nil, "")
+	host := fs.String("host", "", "", YAML("host"))
+	port := fs.Int("port", 0, "", Config("port"))
+	fs.ConfigFlag("config")
+
+	expect(t, fs.Parse([]string{"--config", file}), nil)
+	expect(t, *host, "localhost")
+	expect(t, *port, 8080)
+}
+
+func TestShortCircuit(t *testing.T) {
+	errVersion := fmt.Errorf("version")
+
+	testCases := []struct {
+		args     []string
+		expected error
+	}{
+		{[]string{"--version"}, errVersion},
+		{[]string{"-a", "foo", "--version"}, errVersion},
+		{[]string{"-a", "foo"}, nil},
+		{nil, fmt.Errorf("missing required flags: a")},
+	}
+
+	for _, tt := range testCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fs.String("a", "", "")
+			fs.Lookup("a").Required = true
+			fs.Bool("version", "")
+			fs.SetShortCircuit("version", errVersion)
+
+			expect(t, fs.Parse(tt.args), tt.expected)
+		})
+	}
+}
+
+func TestSourceOnlyUsage(t *testing.T) {
+	fs := NewFlagSet("", "", ContinueOnError)
+	fs.String("user", "", "user name")
+	fs.String("secret", "", "secret")
+	fs.SourceOnly("secret")
+
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	fs.PrintDefaults()
+	expect(t, buf.String(), "  -user string\n  \tuser name\n")
+}
+
+func TestSourceOnly(t *testing.T) {
+	os.Setenv("SOURCE_ONLY_SECRET", "from_env")
+	defer os.Unsetenv("SOURCE_ONLY_SECRET")
+
+	var fs FlagSet
+	secret := fs.String("secret", "", "", Env("SOURCE_ONLY_SECRET"))
+	fs.SourceOnly("secret")
+
+	expect(t, fs.Parse(nil, EnvPrefix("")), nil)
+	expect(t, *secret, "from_env")
+
+	testCases := []struct {
+		args []string
+		err  error
+	}{
+		{[]string{"--secret=foo"}, fmt.Errorf("unknown flag secret")},
+		{[]string{"--secret", "foo"}, fmt.Errorf("unknown flag secret")},
+		{[]string{"--secret="}, fmt.Errorf("invalid flag syntax: --secret=")},
+	}
+
+	for _, tt := range testCases {
+		fs := NewFlagSet("", "", ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		fs.String("secret", "", "", Env("SOURCE_ONLY_SECRET"))
+		fs.SourceOnly("secret")
+
+		expect(t, fs.Parse(tt.args, EnvPrefix("")), tt.err)
+	}
+
+	defer func() {
+		expect(t, recover(), fmt.Errorf("flag undefined is not defined"))
+	}()
+	fs.SourceOnly("undefined")
+}
+
+func TestTrimSpace(t *testing.T) {
+	os.Setenv("TRIM_PORT", " 8080\n")
+	defer os.Unsetenv("TRIM_PORT")
+
+	config := `{"user": "  admin \n", "tags": [" a", "b\n"]}`
+	testCases := []struct {
+		name   string
+		flag   bool
+		global bool
+		user   string
+		tags   []string
+		port   int
+	}{
+		{"off", false, false, "  admin \n", []string{" a", "b\n"}, 0},
+		{"flag", true, false, "admin", []string{"a", "b"}, 8080},
+		{"global", false, true, "admin", []string{"a", "b"}, 8080},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fs.SetTrimSpace(tt.global)
+			user := fs.String("user", "", "", Config("user"))
+			tags := fs.StringList("tags", nil, "", Config("tags"))
+			port := fs.Int("port", 0, "")
+			if tt.port > 0 {
+				// the value can't be parsed as an integer without trimming
+				fs.Lookup("port").Extractors = []Extractor{Env("TRIM_PORT")}
+			}
+			arg := fs.String("arg", "", "")
+			for _, name := range []string{"user", "tags", "port", "arg"} {
+				fs.Lookup(name).TrimSpace = tt.flag
+			}
+
+			err := fs.Parse(
+				[]string{"-arg", " x "},
+				ReaderSource(strings.NewReader(config), json.Unmarshal),
+				EnvPrefix(""),
+			)
+			expect(t, err, nil)
+			expect(t, *user, tt.user)
+			expect(t, *tags, tt.tags)
+			expect(t, *port, tt.port)
+			expect(t, *arg, " x ")
+		})
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("EXPAND_HOME", "/home/foo")
+	os.Unsetenv("EXPAND_UNSET")
+	defer os.Unsetenv("EXPAND_HOME")
+
+	fsys := fstest.MapFS{
+		"config.json": &fstest.MapFile{
+			Data: []byte(`{
+				"cache": "${EXPAND_HOME}/cache",
+				"paths": ["$EXPAND_HOME/a", "${EXPAND_UNSET}/b"],
+				"unset": "x${EXPAND_UNSET}y",
+				"raw": "${EXPAND_HOME}"
+			}`),
+		},
+	}
+
+	var fs FlagSet
+	cache := fs.String("cache", "", "", Config("cache"))
+	paths := fs.StringList("paths", nil, "", Config("paths"))
+	unset := fs.String("unset", "", "", Config("unset"))
+	raw := fs.String("raw", "", "", Config("raw"))
+	arg := fs.String("arg", "", "")
+	for _, name := range []string{"cache", "paths", "unset", "arg"} {
+		fs.Lookup(name).ExpandEnv = true
+	}
+
+	err := fs.Parse(
+		[]string{"-arg", "$EXPAND_HOME"},
+		FSVia(fsys, "config.json", json.Unmarshal),
+	)
+	expect(t, err, nil)
+	expect(t, *cache, "/home/foo/cache")
+	expect(t, *paths, []string{"/home/foo/a", "/b"})
+	expect(t, *unset, "xy")
+	expect(t, *raw, "${EXPAND_HOME}")
+	expect(t, *arg, "$EXPAND_HOME")
+}
+
+func TestAllowExpressions(t *testing.T) {
+	os.Setenv("EXPRESSIONS_TIMEOUT", "30*1000")
+	defer os.Unsetenv("EXPRESSIONS_TIMEOUT")
+
+	var fs FlagSet
+	workers := fs.Int("workers", 1, "")
+	timeout := fs.Int64("timeout", 0, "", Env("EXPRESSIONS_TIMEOUT"))
+	ratio := fs.Float("ratio", 0, "")
+	for _, name := range []string{"workers", "timeout", "ratio"} {
+		fs.Lookup(name).AllowExpressions = true
+	}
+
+	expect(t, fs.Parse([]string{"--workers", "2*4", "--ratio=1/4"}, EnvPrefix("")), nil)
+	expect(t, *workers, 8)
+	expect(t, *timeout, int64(30000))
+	expect(t, *ratio, 0.25)
+
+	fs2 := NewFlagSet("", "", ContinueOnError)
+	workers = fs2.Int("workers", 1, "")
+	fs2.Lookup("workers").AllowExpressions = true
+	expect(t, fs2.Parse([]string{"--workers", "1_000*2"}), nil)
+	expect(t, *workers, 2000)
+
+	fs2 = NewFlagSet("", "", ContinueOnError)
+	fs2.SetOutput(ioutil.Discard)
+	fs2.Int("workers", 1, "")
+	err := fs2.Parse([]string{"--workers", "2*4"})
+	expect(t, err != nil, true)
+
+	fs2 = NewFlagSet("", "", ContinueOnError)
+	fs2.SetOutput(ioutil.Discard)
+	fs2.Int("workers", 1, "")
+	fs2.Lookup("workers").AllowExpressions = true
+	err = fs2.Parse([]string{"--workers", "len(x)"})
+	expect(t, err, fmt.Errorf(`invalid expression "len(x)": only numbers and arithmetic operators are allowed`))
+}
+
+func TestDurationListMixed(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config.json": &fstest.MapFile{
+			Data: []byte(`{"mixed": ["1s", 2000000000, "3m"], "invalid": ["1s", "2"]}`),
+		},
+	}
+
+	var fs FlagSet
+	mixed := fs.DurationList("mixed", nil, "", Config("mixed"))
+	expect(t, fs.Parse(nil, FSVia(fsys, "config.json", json.Unmarshal)), nil)
+	expect(t, *mixed, []time.Duration{time.Second, 2 * time.Second, 3 * time.Minute})
+
+	var fs2 FlagSet
+	fs2.DurationList("invalid", nil, "", Config("invalid"))
+	err := fs2.Parse(nil, FSVia(fsys, "config.json", json.Unmarshal))
+	expect(t, err.Error(), `invalid list elements: element 1: time: missing unit in duration "2"`)
+}
+
+func TestDurationSeconds(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config.json": &fstest.MapFile{
+			Data: []byte(`{
+				"timeout": 30,
+				"unit": "30ms",
+				"list": [1, "2m"]
+			}`),
+		},
+	}
+
+	testCases := []struct {
+		seconds bool
+		timeout time.Duration
+		unit    time.Duration
+		list    []time.Duration
+	}{
+		{
+			true,
+			30 * time.Second,
+			30 * time.Millisecond,
+			[]time.Duration{time.Second, 2 * time.Minute},
+		},
+		{
+			false,
+			30 * time.Nanosecond,
+			30 * time.Millisecond,
+			[]time.Duration{time.Nanosecond, 2 * time.Minute},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(fmt.Sprint(tt.seconds), func(t *testing.T) {
+			var fs FlagSet
+			timeout := fs.Duration("timeout", 0, "", Config("timeout"))
+			unit := fs.Duration("unit", 0, "", Config("unit"))
+			list := fs.DurationList("list", nil, "", Config("list"))
+			for _, name := range []string{"timeout", "unit", "list"} {
+				fs.Lookup(name).DurationSeconds = tt.seconds
+			}
+
+			err := fs.Parse(nil, FSVia(fsys, "config.json", json.Unmarshal))
+			expect(t, err, nil)
+			expect(t, *timeout, tt.timeout)
+			expect(t, *unit, tt.unit)
+			expect(t, *list, tt.list)
+		})
+	}
+
+	os.Setenv("DURATION_SECONDS_ENV", "1.5")
+	defer os.Unsetenv("DURATION_SECONDS_ENV")
+
+	var fs FlagSet
+	env := fs.Duration("env", 0, "", Env("DURATION_SECONDS_ENV"))
+	arg := fs.Duration("arg", 0, "")
+	fs.Lookup("env").DurationSeconds = true
+	fs.Lookup("arg").DurationSeconds = true
+
+	expect(t, fs.Parse([]string{"-arg=2s"}, EnvPrefix("")), nil)
+	expect(t, *env, 1500*time.Millisecond)
+	expect(t, *arg, 2*time.Second)
+}
+
+func TestLenientBool(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		config   string
+		lenient  bool
+		expected bool
+		err      bool
+	}{
+		{"config yes", nil, `{"v": "yes"}`, true, true, false},
+		{"config ON", nil, `{"v": "ON"}`, true, true, false},
+		{"config off", nil, `{"v": "off"}`, true, false, false},
+		{"config no", nil, `{"v": "no"}`, true, false, false},
+		{"config true", nil, `{"v": true}`, true, true, false},
+		{"config invalid", nil, `{"v": "maybe"}`, true, false, true},
+		{"config yes without option", nil, `{"v": "yes"}`, false, false, true},
+		{"args yes", []string{"--v=yes"}, `{}`, true, false, true},
+		{"args true", []string{"--v=true"}, `{}`, true, true, false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			v := fs.Bool("v", "", Config("v"))
+			fs.Lookup("v").LenientBool = tt.lenient
+
+			err := fs.Parse(tt.args, ReaderSource(strings.NewReader(tt.config), json.Unmarshal))
+			expect(t, err != nil, tt.err)
+			expect(t, *v, tt.expected)
+		})
+	}
+}
+
+func TestEmptyIsTrue(t *testing.T) {
+	testCases := []struct {
+		name     string
+		env      *string
+		empty    bool
+		expected bool
+		err      bool
+	}{
+		{"present empty", strPtr(""), true, true, false},
+		{"present false", strPtr("false"), true, false, false},
+		{"present true", strPtr("true"), true, true, false},
+		{"absent", nil, true, false, false},
+		{"present empty without option", strPtr(""), false, false, true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Unsetenv("EMPTY_IS_TRUE_DEBUG")
+			if tt.env != nil {
+				os.Setenv("EMPTY_IS_TRUE_DEBUG", *tt.env)
+			}
+			defer os.Unsetenv("EMPTY_IS_TRUE_DEBUG")
+
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			debug := fs.Bool("debug", "", Env("EMPTY_IS_TRUE_DEBUG"))
+			fs.Lookup("debug").EmptyIsTrue = tt.empty
+
+			err := fs.Parse(nil, EnvPrefix(""))
+			expect(t, err != nil, tt.err)
+			expect(t, *debug, tt.expected)
+		})
+	}
+}
+
+func strPtr(s string) *string { return &s }
+
+func TestMergeSources(t *testing.T) {
+	os.Setenv("MERGE_SOURCES_TAGS", "env")
+	defer os.Unsetenv("MERGE_SOURCES_TAGS")
+
+	fsys := fstest.MapFS{
+		"config.json": &fstest.MapFile{
+			Data: []byte(`{"tags": ["a", "b"], "ports": [1, 2]}`),
+		},
+	}
+
+	testCases := []struct {
+		merge    bool
+		tags     []string
+		ports    []int
+		fallback []string
+	}{
+		{false, []string{"a", "b"}, []int{1, 2}, []string{"default"}},
+		{true, []string{"a", "b", "env"}, []int{1, 2}, []string{"default"}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(fmt.Sprint(tt.merge), func(t *testing.T) {
+			var fs FlagSet
+			tags := fs.StringList("tags", nil, "", Config("tags"), Env("MERGE_SOURCES_TAGS"))
+			ports := fs.IntList("ports", nil, "", Env("MERGE_SOURCES_PORTS"), Config("ports"))
+			fallback := fs.StringList("fallback", []string{"default"}, "", Config("fallback"))
+			for _, name := range []string{"tags", "ports", "fallback"} {
+				fs.Lookup(name).MergeSources = tt.merge
+			}
+
+			err := fs.Parse(
+				nil,
+				FSVia(fsys, "config.json", json.Unmarshal),
+				EnvPrefix(""),
+			)
+			expect(t, err, nil)
+			expect(t, *tags, tt.tags)
+			expect(t, *ports, tt.ports)
+			expect(t, *fallback, tt.fallback)
+		})
+	}
+}
+
+func TestMergeSourcesValueType(t *testing.T) {
+	os.Setenv("MERGE_SOURCES_PORTS", "3")
+	defer os.Unsetenv("MERGE_SOURCES_PORTS")
+
+	fsys := fstest.MapFS{
+		"config.json": &fstest.MapFile{Data: []byte(`{"ports": [1, 2]}`)},
+	}
+
+	testCases := []struct {
+		appendArgs bool
+		args       []string
+		expected   []int
+	}{
+		{false, nil, []int{1, 2, 3}},
+		{true, []string{"--ports=4"}, []int{1, 2, 3, 4}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(fmt.Sprint(tt.appendArgs), func(t *testing.T) {
+			var fs FlagSet
+			ports := fs.IntList("ports", nil, "", Config("ports"), Env("MERGE_SOURCES_PORTS"))
+			f := fs.Lookup("ports")
+			f.MergeSources = true
+			f.AppendArgs = tt.appendArgs
+			// the type of the list comes from the value, not the default
+			f.Default = nil
+
+			err := fs.Parse(tt.args, FSVia(fsys, "config.json", json.Unmarshal), EnvPrefix(""))
+			expect(t, err, nil)
+			expect(t, *ports, tt.expected)
+		})
+	}
+}
+
+func TestRequireParse(t *testing.T) {
+	accessors := map[string]func(*FlagSet){
+		"NFlags":      func(fs *FlagSet) { fs.NFlags() },
+		"NArg":        func(fs *FlagSet) { fs.NArg() },
+		"Args":        func(fs *FlagSet) { fs.Args() },
+		"Arg":         func(fs *FlagSet) { fs.Arg(0) },
+		"UnknownArgs": func(fs *FlagSet) { fs.UnknownArgs() },
+	}
+
+	for name, fn := range accessors {
+		t.Run(name, func(t *testing.T) {
+			var fs FlagSet
+			fs.SetRequireParse(true)
+
+			func() {
+				defer func() {
+					expect(t, recover(), ErrNotParsed)
+				}()
+				fn(&fs)
+			}()
 
+			expect(t, fs.Parse(nil), nil)
+			fn(&fs)
+		})
+	}
+
+	var fs FlagSet
+	expect(t, fs.NArg(), 0)
+}
+
+func TestApplyDefaults(t *testing.T) {
+	var fs FlagSet
+	s := fs.String("s", "a", "")
+	i := fs.Int("i", 1, "")
+	d := fs.Duration("d", time.Second, "")
+	l := fs.IntList("l", nil, "")
+	b := fs.Bool("b", "")
+
+	err := fs.ApplyDefaults(map[string]interface{}{
+		"s": "b",
+		"i": "2",
+		"d": "1m",
+		"l": []interface{}{float64(1), "2"},
+		"b": true,
+	})
 	expect(t, err, nil)
-	expect(t, remaining, []string{})
-	expect(t, *x, 5)
+	expect(t, fs.Lookup("i").Default, 2)
+
+	expect(t, fs.Parse([]string{"-i=3"}), nil)
+	expect(t, *s, "b")
+	expect(t, *i, 3)
+	expect(t, *d, time.Minute)
+	expect(t, *l, []int{1, 2})
+	expect(t, *b, true)
 }
 
-func TestParseNextInvalidFlagSyntax(t *testing.T) {
+func TestApplyDefaultsErrors(t *testing.T) {
 	var fs FlagSet
+	fs.String("s", "a", "")
+	fs.Int("i", 1, "")
 
-	_, err := fs.parseNext([]string{"---x=5"})
-	expect(t, err, fmt.Errorf("invalid flag syntax: ---x=5"))
+	err := fs.ApplyDefaults(map[string]interface{}{
+		"s": "b",
+		"x": 1,
+	})
+	expect(t, err, fmt.Errorf("unknown flag x"))
+	expect(t, fs.Lookup("s").Default, "a")
 
-	_, err = fs.parseNext([]string{"-x="})
-	expect(t, err, fmt.Errorf("invalid flag syntax: -x="))
+	err = fs.ApplyDefaults(map[string]interface{}{
+		"s": "b",
+		"i": true,
+	})
+	expect(t, err, fmt.Errorf("invalid default value for flag i: cannot assign type bool to int"))
+	expect(t, fs.Lookup("s").Default, "a")
 }
 
-func TestParse(t *testing.T) {
+func TestApplyDefaultsTextVar(t *testing.T) {
 	var fs FlagSet
+	var addr netip.Addr
+	fs.TextVar(&addr, "addr", "")
 
-	os.Setenv("TEST_B", "env_bar")
-	os.Setenv("TEST_C", "env_baz")
+	expect(t, fs.ApplyDefaults(map[string]interface{}{"addr": "127.0.0.1"}), nil)
+	expect(t, fs.Lookup("addr").Default, netip.MustParseAddr("127.0.0.1"))
 
-	a := fs.String("a", "", "")
-	b := fs.String("b", "", "", Env("TEST_B"))
-	c := fs.String("c", "", "", Env("TEST_C"))
-	d := fs.String("d", "default", "", Env("TEST_D"))
+	expect(t, fs.Parse(nil), nil)
+	expect(t, addr, netip.MustParseAddr("127.0.0.1"))
 
-	expect(t, fs.Parsed(), false)
+	err := fs.ApplyDefaults(map[string]interface{}{"addr": "localhost"})
+	expect(t, err, fmt.Errorf(`invalid default value for flag addr: ParseAddr("localhost"): unable to parse IP`))
+	expect(t, fs.Lookup("addr").Default, netip.MustParseAddr("127.0.0.1"))
+}
 
-	err := fs.Parse([]string{"-a=foo", "-b=bar", "foo", "bar"}, EnvPrefix(""))
-	expect(t, err, nil)
+func TestResetFlag(t *testing.T) {
+	var fs FlagSet
+	host := fs.String("host", "localhost", "")
+	tags := fs.StringList("tag", []string{"a"}, "")
+	port := fs.Int("port", 8080, "")
 
-	expect(t, *a, "foo")
-	expect(t, *b, "bar")
-	expect(t, *c, "env_baz")
-	expect(t, *d, "default")
-	expect(t, fs.Parsed(), true)
-	expect(t, fs.NArg(), 2)
-	expect(t, fs.Args(), []string{"foo", "bar"})
-	expect(t, fs.Arg(0), "foo")
+	expect(t, fs.Parse([]string{"--host", "example.com", "--tag", "b", "--port=9090"}), nil)
+	expect(t, *host, "example.com")
 	expect(t, fs.NFlags(), 3)
+
+	expect(t, fs.ResetFlag("host"), nil)
+	expect(t, fs.ResetFlag("tag"), nil)
+	expect(t, *host, "localhost")
+	expect(t, *tags, []string{"a"})
+	expect(t, *port, 9090)
+	expect(t, fs.NFlags(), 1)
+	expect(t, fs.ArgIndex("host"), -1)
+	_, ok := fs.origins["host"]
+	expect(t, ok, false)
+	expect(t, fs.origins["port"], OriginArgs)
+
+	expect(t, fs.ApplyArg("--tag=c"), nil)
+	expect(t, *tags, []string{"c"})
+
+	expect(t, fs.ResetFlag("missing"), fmt.Errorf("unknown flag missing"))
 }
 
 func TestString(t *testing.T) {
@@ -144,6 +2038,43 @@ func TestString(t *testing.T) {
 	expect(t, *x, "foo")
 }
 
+func TestMustBeUTF8(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		env      string
+		expected error
+	}{
+		{"valid", []string{"-s", "héllo", "-l", "ñ"}, "日本", nil},
+		{"invalid arg", []string{"-s", "a\xffb"}, "", fmt.Errorf("invalid UTF-8 value for flag s")},
+		{"invalid list", []string{"-l", "ok", "-l", "\xc3\x28"}, "", fmt.Errorf("invalid UTF-8 value for flag l")},
+		{"invalid env", nil, "\xe2\x82", fmt.Errorf("invalid UTF-8 value for flag e")},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("TEST_UTF8", tt.env)
+			defer os.Unsetenv("TEST_UTF8")
+
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fs.String("s", "", "")
+			fs.StringList("l", nil, "")
+			fs.String("e", "", "", Env("TEST_UTF8"))
+			raw := fs.String("raw", "", "")
+			for _, name := range []string{"s", "l", "e"} {
+				fs.Lookup(name).MustBeUTF8 = true
+			}
+
+			args := append(tt.args, "-raw", "\xff")
+			expect(t, fs.Parse(args, EnvPrefix("")), tt.expected)
+			if tt.expected == nil {
+				expect(t, *raw, "\xff")
+			}
+		})
+	}
+}
+
 func TestStringList(t *testing.T) {
 	var fs FlagSet
 	x := fs.StringList("x", nil, "")
@@ -158,6 +2089,131 @@ func TestBool(t *testing.T) {
 	expect(t, *x, true)
 }
 
+func TestBoolInline(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		expected bool
+	}{
+		{[]string{"-x"}, true},
+		{[]string{"-x=1"}, true},
+		{[]string{"-x=0"}, false},
+		{[]string{"-x=true"}, true},
+		{[]string{"-x=false"}, false},
+		{[]string{"--x=1"}, true},
+		{[]string{"--x=0"}, false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var fs FlagSet
+			x := fs.Bool("x", "")
+			expect(t, fs.Parse(tt.args), nil)
+			expect(t, *x, tt.expected)
+		})
+	}
+
+	var fs FlagSet
+	fs.Bool("x", "")
+	_, err := fs.parseNext([]string{"-x=2"})
+	if err == nil {
+		t.Errorf("expecting error for -x=2, got nil instead")
+	}
+}
+
+func TestNegatableBool(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		expected bool
+	}{
+		{[]string{"--color"}, true},
+		{[]string{"--no-color"}, false},
+		{[]string{"--color=true"}, true},
+		{[]string{"--color=false"}, false},
+		{[]string{"-no-color"}, false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.args[0], func(t *testing.T) {
+			var fs FlagSet
+			color := fs.NegatableBool("color", "")
+			fs.Bool("other", "")
+			*color = !tt.expected
+
+			expect(t, fs.Parse(tt.args), nil)
+			expect(t, *color, tt.expected)
+		})
+	}
+
+	fs := NewFlagSet("", "", ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.Bool("other", "")
+	expect(t, fs.Parse([]string{"--no-other"}), fmt.Errorf("unknown flag no-other"))
+}
+
+func TestShorthand(t *testing.T) {
+	testCases := [][]string{
+		{"-n", "foo", "-v", "-c=3", "-t", "a", "--tag", "b"},
+		{"--name", "foo", "--verbose", "--count=3", "-tag", "a", "-t", "b"},
+	}
+
+	for _, args := range testCases {
+		t.Run(args[0], func(t *testing.T) {
+			var fs FlagSet
+			name := fs.StringP("name", "n", "", "")
+			verbose := fs.BoolP("verbose", "v", "")
+			count := fs.IntP("count", "c", 0, "")
+			tags := fs.StringListP("tag", "t", nil, "")
+
+			expect(t, fs.Parse(args), nil)
+			expect(t, *name, "foo")
+			expect(t, *verbose, true)
+			expect(t, *count, 3)
+			expect(t, *tags, []string{"a", "b"})
+			expect(t, fs.Lookup("name").Shorthand, "n")
+		})
+	}
+
+	var fs FlagSet
+	fs.StringP("name", "n", "", "")
+	fs.String("x", "", "")
+	for _, short := range []string{"n", "x"} {
+		func() {
+			defer func() {
+				expect(t, recover() != nil, true)
+			}()
+			fs.IntP("other-"+short, short, 0, "")
+		}()
+	}
+}
+
+func TestBoolNextToken(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		enabled  bool
+		expected bool
+		rest     []string
+	}{
+		{[]string{"--verbose", "true"}, true, true, nil},
+		{[]string{"--verbose", "false"}, true, false, nil},
+		{[]string{"--verbose", "No", "x"}, true, false, []string{"x"}},
+		{[]string{"--verbose", "yes"}, true, true, nil},
+		{[]string{"--verbose", "somefile"}, true, true, []string{"somefile"}},
+		{[]string{"--verbose", "false"}, false, true, []string{"false"}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(fmt.Sprint(tt.args, tt.enabled), func(t *testing.T) {
+			var fs FlagSet
+			fs.SetBoolNextToken(tt.enabled)
+			verbose := fs.Bool("verbose", "")
+
+			expect(t, fs.Parse(tt.args), nil)
+			expect(t, *verbose, tt.expected)
+			expect(t, fs.Args(), tt.rest)
+		})
+	}
+}
+
 func TestInt(t *testing.T) {
 	var fs FlagSet
 	x := fs.Int("x", 0, "")
@@ -165,6 +2221,54 @@ func TestInt(t *testing.T) {
 	expect(t, *x, 5)
 }
 
+func TestIntGrouping(t *testing.T) {
+	var fs FlagSet
+	limit := fs.Int("limit", 0, "")
+	expect(t, fs.Parse([]string{"--limit", "1_000_000"}), nil)
+	expect(t, *limit, 1000000)
+
+	fs = FlagSet{}
+	fs.Int("limit", 0, "")
+	_, err := fs.parseNext([]string{"--limit", "1,000,000"})
+	if err == nil {
+		t.Errorf("expecting error grouping with commas, got nil instead")
+	}
+
+	fs = FlagSet{}
+	limit = fs.Int("limit", 0, "")
+	sizes := fs.Uint64List("sizes", nil, "")
+	fs.Lookup("limit").AllowGrouping = true
+	fs.Lookup("sizes").AllowGrouping = true
+	expect(t, fs.Parse([]string{"--limit", "1,000,000", "--sizes=1,024", "--sizes=2_048"}), nil)
+	expect(t, *limit, 1000000)
+	expect(t, *sizes, []uint64{1024, 2048})
+}
+
+func TestListReplacesDefault(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		expected []string
+	}{
+		{nil, []string{"d1", "d2"}},
+		{[]string{"-x=a"}, []string{"a"}},
+		{[]string{"-x=a", "-x", "b"}, []string{"a", "b"}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var fs FlagSet
+			x := []string{"d1", "d2"}
+			fs.StringListVar(&x, "x", []string{"d1", "d2"}, "")
+			weights := map[string]int{"d": 1}
+			fs.IntMapVar(&weights, "w", map[string]int{"d": 1}, "")
+
+			expect(t, fs.Parse(append(tt.args, "-w", "a=2")), nil)
+			expect(t, x, tt.expected)
+			expect(t, weights, map[string]int{"a": 2})
+		})
+	}
+}
+
 func TestIntList(t *testing.T) {
 	var fs FlagSet
 	x := fs.IntList("x", nil, "")
@@ -235,6 +2339,293 @@ func TestFloat(t *testing.T) {
 	expect(t, *x, 3.14)
 }
 
+func TestRate(t *testing.T) {
+	testCases := []struct {
+		arg      string
+		expected float64
+	}{
+		{"-x=100/s", 100},
+		{"-x=120/m", 2},
+		{"-x=7200 / h", 2},
+		{"-x=2.5", 2.5},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.arg, func(t *testing.T) {
+			var fs FlagSet
+			x := fs.Rate("x", 0, "")
+			expect(t, fs.Parse([]string{tt.arg}), nil)
+			expect(t, *x, tt.expected)
+		})
+	}
+
+	var fs FlagSet
+	x := fs.Rate("x", 1, "")
+	expect(t, fs.Parse(nil), nil)
+	expect(t, *x, float64(1))
+
+	err := rateValue{x}.Set("10/d")
+	expect(t, err, fmt.Errorf(`invalid rate unit in "10/d", expecting s, m or h`))
+
+	expect(t, rateValue{x}.Set(int64(3)), nil)
+	expect(t, *x, float64(3))
+}
+
+func TestEnum(t *testing.T) {
+	choices := []string{"debug", "info", "WARN"}
+	testCases := []struct {
+		args       []string
+		ignoreCase bool
+		expected   string
+		err        error
+	}{
+		{nil, false, "info", nil},
+		{[]string{"--level", "debug"}, false, "debug", nil},
+		{[]string{"--level", "WARN"}, false, "WARN", nil},
+		{[]string{"--level", "INFO"}, false, "", fmt.Errorf(`invalid value "INFO", expecting one of: debug, info, WARN`)},
+		{[]string{"--level", "INFO"}, true, "info", nil},
+		{[]string{"--level=Debug"}, true, "debug", nil},
+		{[]string{"--level", "warn"}, true, "WARN", nil},
+		{[]string{"--level", "trace"}, true, "", fmt.Errorf(`invalid value "trace", expecting one of: debug, info, WARN`)},
+	}
+
+	for _, tt := range testCases {
+		t.Run(fmt.Sprint(tt.ignoreCase, tt.args), func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			level := fs.Enum("level", "info", choices, tt.ignoreCase, "")
+
+			expect(t, fs.Parse(tt.args), tt.err)
+			expect(t, *level, tt.expected)
+		})
+	}
+
+	os.Setenv("ENUM_LEVEL", "Warn")
+	defer os.Unsetenv("ENUM_LEVEL")
+
+	var fs FlagSet
+	level := fs.Enum("level", "debug", choices, true, "", Env("ENUM_LEVEL"))
+	format := fs.Enum("format", "", []string{"", "json", "text"}, false, "")
+	color := fs.Enum("color", "Auto", []string{"auto", "always", "never"}, true, "")
+	expect(t, fs.Parse(nil, EnvPrefix("")), nil)
+	expect(t, *level, "WARN")
+	expect(t, *format, "")
+	expect(t, *color, "auto")
+	expect(t, fs.Lookup("color").Default, "auto")
+
+	err := fs.ApplyDefaults(map[string]interface{}{"format": "xml"})
+	expect(t, err, fmt.Errorf(`invalid default value for flag format: invalid value "xml", expecting one of: , json, text`))
+}
+
+func TestEnumInvalidDefault(t *testing.T) {
+	defer func() {
+		expect(t, recover(), fmt.Errorf(`flag level: invalid default value: invalid value "INFO", expecting one of: debug, info`))
+	}()
+
+	var fs FlagSet
+	fs.Enum("level", "INFO", []string{"debug", "info"}, false, "")
+}
+
+func TestTimeOfDay(t *testing.T) {
+	testCases := []struct {
+		arg      string
+		expected TimeOfDay
+		err      error
+	}{
+		{"-x=14:30", TimeOfDay{14, 30, 0}, nil},
+		{"-x=00:00:59", TimeOfDay{0, 0, 59}, nil},
+		{"-x=23:59:59", TimeOfDay{23, 59, 59}, nil},
+		{"-x=25:00", TimeOfDay{}, fmt.Errorf(`time of day "25:00" is out of range`)},
+		{"-x=12:60", TimeOfDay{}, fmt.Errorf(`time of day "12:60" is out of range`)},
+		{"-x=9:30", TimeOfDay{}, fmt.Errorf(`invalid time of day "9:30", expecting HH:MM or HH:MM:SS`)},
+		{"-x=noon", TimeOfDay{}, fmt.Errorf(`invalid time of day "noon", expecting HH:MM or HH:MM:SS`)},
+		{"-x=12:00:00:00", TimeOfDay{}, fmt.Errorf(`invalid time of day "12:00:00:00", expecting HH:MM or HH:MM:SS`)},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.arg, func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			x := fs.TimeOfDay("x", TimeOfDay{}, "")
+			expect(t, fs.Parse([]string{tt.arg}), tt.err)
+			expect(t, *x, tt.expected)
+		})
+	}
+
+	os.Setenv("TEST_TIME_OF_DAY", "08:15")
+	defer os.Unsetenv("TEST_TIME_OF_DAY")
+
+	var fs FlagSet
+	x := fs.TimeOfDay("x", TimeOfDay{12, 0, 0}, "", Env("TEST_TIME_OF_DAY"))
+	y := fs.TimeOfDay("y", TimeOfDay{12, 0, 0}, "")
+	expect(t, fs.Parse(nil, EnvPrefix("")), nil)
+	expect(t, *x, TimeOfDay{8, 15, 0})
+	expect(t, *y, TimeOfDay{12, 0, 0})
+	expect(t, x.Duration(), 8*time.Hour+15*time.Minute)
+	expect(t, x.String(), "08:15:00")
+}
+
+func TestTime(t *testing.T) {
+	testCases := []struct {
+		arg      string
+		layout   string
+		expected time.Time
+		err      bool
+	}{
+		{"-x=2024-03-01", "2006-01-02", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), false},
+		{"-x=2024-03-01T10:30:00Z", "", time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC), false},
+		{"-x=2024-03-01", "", time.Time{}, true},
+		{"-x=1709251200", "", time.Time{}, true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.arg, func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			x := fs.Time("x", time.Time{}, "", tt.layout)
+			expect(t, fs.Parse([]string{tt.arg}) != nil, tt.err)
+			expect(t, *x, tt.expected)
+		})
+	}
+
+	config := `{"start": "2024-03-01T10:30:00+02:00", "end": 1709251200, "day": "01/03/2024"}`
+	def := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var fs FlagSet
+	start := fs.Time("start", def, "", time.RFC3339, Config("start"))
+	end := fs.Time("end", def, "", "", Config("end"))
+	day := fs.Time("day", def, "", "02/01/2006", Config("day"))
+	other := fs.Time("other", def, "", "")
+	err := fs.Parse(nil, ReaderSource(strings.NewReader(config), json.Unmarshal))
+	expect(t, err, nil)
+	expect(t, start.Equal(time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)), true)
+	expect(t, end.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)), true)
+	expect(t, *day, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	expect(t, *other, def)
+
+	expect(t, fs.FlagUsage("other"), "  -other time.Time\n  \t (default value: 2020-01-01T00:00:00Z)\n")
+
+	var v time.Time
+	expect(t, NewValue(&v).Set("2024-03-01T00:00:00Z"), nil)
+	expect(t, v, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	expect(t, NewValue(&v).Set(1.5), nil)
+	expect(t, v.Equal(time.Unix(1, int64(time.Second/2))), true)
+	expect(t, NewValue(&v).Set(true), fmt.Errorf("cannot assign type bool to time.Time"))
+}
+
+func TestIPList(t *testing.T) {
+	os.Setenv("TEST_DNS", "1.1.1.1,8.8.8.8")
+	defer os.Unsetenv("TEST_DNS")
+
+	fsys := fstest.MapFS{
+		"config.json": &fstest.MapFile{
+			Data: []byte(`{"dns": ["9.9.9.9", "::1"], "proxies": ["http://a.com", "http://b.com"]}`),
+		},
+	}
+
+	var fs FlagSet
+	args := fs.IPList("args", nil, "")
+	env := fs.IPList("env", nil, "", Env("TEST_DNS"))
+	file := fs.IPList("file", nil, "", Config("dns"))
+	proxies := fs.URLList("proxies", nil, "", Config("proxies"))
+	upstream := fs.URL("upstream", url.URL{Scheme: "http", Host: "localhost"}, "")
+
+	err := fs.Parse(
+		[]string{"-args", "10.0.0.1", "--args", "10.0.0.2,10.0.0.3"},
+		EnvPrefix(""),
+		FSVia(fsys, "config.json", json.Unmarshal),
+	)
+	expect(t, err, nil)
+	expect(t, *args, []net.IP{
+		net.ParseIP("10.0.0.1"),
+		net.ParseIP("10.0.0.2"),
+		net.ParseIP("10.0.0.3"),
+	})
+	expect(t, *env, []net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("8.8.8.8")})
+	expect(t, *file, []net.IP{net.ParseIP("9.9.9.9"), net.ParseIP("::1")})
+	expect(t, *proxies, []url.URL{
+		{Scheme: "http", Host: "a.com"},
+		{Scheme: "http", Host: "b.com"},
+	})
+	expect(t, *upstream, url.URL{Scheme: "http", Host: "localhost"})
+}
+
+func TestMaps(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config.json": &fstest.MapFile{
+			Data: []byte(`{"limits": {"a": 10, "b": "20"}, "toggles": {"x": true, "y": "false"}}`),
+		},
+	}
+
+	var fs FlagSet
+	weights := fs.IntMap("weight", nil, "")
+	features := fs.BoolMap("feature", nil, "")
+	limits := fs.IntMap("limits", nil, "", Config("limits"))
+	toggles := fs.BoolMap("toggles", nil, "", Config("toggles"))
+	defaults := fs.IntMap("defaults", map[string]int{"a": 1}, "")
+
+	err := fs.Parse(
+		[]string{"--weight", "a=1", "--weight=b=2", "--feature", "x=true", "--weight", "a=3"},
+		FSVia(fsys, "config.json", json.Unmarshal),
+	)
+	expect(t, err, nil)
+	expect(t, *weights, map[string]int{"a": 3, "b": 2})
+	expect(t, *features, map[string]bool{"x": true})
+	expect(t, *limits, map[string]int{"a": 10, "b": 20})
+	expect(t, *toggles, map[string]bool{"x": true, "y": false})
+	expect(t, *defaults, map[string]int{"a": 1})
+
+	testCases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"--weight", "a"}, `invalid key=value pair "a"`},
+		{[]string{"--weight", "=1"}, `invalid key=value pair "=1"`},
+		{[]string{"--weight", "a=x"}, `invalid value for key a: `},
+		{[]string{"--feature", "x=maybe"}, `invalid value for key x: `},
+	}
+
+	for _, tt := range testCases {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fs.IntMap("weight", nil, "")
+			fs.BoolMap("feature", nil, "")
+
+			err := fs.Parse(tt.args)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.expected) {
+				t.Errorf("expecting error starting with %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestTextVar(t *testing.T) {
+	os.Setenv("TEST_TEXT_ADDR", "::1")
+	defer os.Unsetenv("TEST_TEXT_ADDR")
+
+	var fs FlagSet
+	var arg, env, def netip.Addr
+	def = netip.MustParseAddr("127.0.0.1")
+	fs.TextVar(&arg, "arg", "")
+	fs.TextVar(&env, "env", "", Env("TEST_TEXT_ADDR"))
+	fs.TextVar(&def, "def", "")
+
+	err := fs.Parse([]string{"-arg", "10.0.0.1"}, EnvPrefix(""))
+	expect(t, err, nil)
+	expect(t, arg, netip.MustParseAddr("10.0.0.1"))
+	expect(t, env, netip.MustParseAddr("::1"))
+	expect(t, def, netip.MustParseAddr("127.0.0.1"))
+	expect(t, fs.Lookup("arg").Value.(Getter).String(), "10.0.0.1")
+
+	fs2 := NewFlagSet("", "", ContinueOnError)
+	fs2.SetOutput(ioutil.Discard)
+	fs2.TextVar(new(netip.Addr), "addr", "")
+	if err := fs2.Parse([]string{"-addr", "foo"}); err == nil {
+		t.Errorf("expecting error parsing invalid address, got nil instead")
+	}
+}
+
 func TestFloatList(t *testing.T) {
 	var fs FlagSet
 	x := fs.FloatList("x", nil, "")
@@ -267,6 +2658,35 @@ func TestUsage(t *testing.T) {
 
 	expect(t, buf.String(), expected)
 
+	buf.Reset()
+	fs.SubCommand("run", "runs something")
+	fs.SubCommand("stop", "")
+	fs.printUsage()
+	expect(t, buf.String(), expected+"\n"+
+		"Subcommands:\n"+
+		"  run\n"+
+		"  \truns something\n"+
+		"  stop\n",
+	)
+
+	buf.Reset()
+	fs.AddHelpSection("Examples", "foo -a run\nfoo stop")
+	fs.AddHelpSection("Exit codes", "2 on invalid usage")
+	fs.printUsage()
+	expect(t, buf.String(), expected+"\n"+
+		"Subcommands:\n"+
+		"  run\n"+
+		"  \truns something\n"+
+		"  stop\n"+
+		"\n"+
+		"Examples:\n"+
+		"  foo -a run\n"+
+		"  foo stop\n"+
+		"\n"+
+		"Exit codes:\n"+
+		"  2 on invalid usage\n",
+	)
+
 	buf.Reset()
 	fs.Usage = func() {
 		buf.WriteString("hello")
@@ -276,6 +2696,86 @@ func TestUsage(t *testing.T) {
 	expect(t, buf.String(), "hello")
 }
 
+func TestUsageNegatableBool(t *testing.T) {
+	fs := NewFlagSet("foo", "", ContinueOnError)
+	fs.NegatableBool("a", "flag a")
+
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+
+	fs.PrintDefaults()
+	expect(t, buf.String(), "  -[no-]a bool\n  \tflag a (default value: false)\n")
+}
+
+func TestUsageShorthands(t *testing.T) {
+	fs := NewFlagSet("foo", "", ContinueOnError)
+	fs.StringP("name", "n", "", "name of the thing")
+	fs.BoolP("verbose", "v", "verbose output")
+	fs.Lookup("verbose").Negatable = true
+	fs.IntList("port", nil, "ports")
+	fs.setShorthand("port", "p")
+	fs.String("output", "", "output file")
+	fs.DurationP("duration", "d", time.Second, "timeout")
+
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	fs.PrintDefaults()
+
+	expected := "  -n, --name string\n" +
+		"  \tname of the thing\n" +
+		"  -v, --[no-]verbose bool\n" +
+		"  \tverbose output (default value: false)\n" +
+		"  -p, --port list of int\n" +
+		"  \tports (default value: [])\n" +
+		"  -output string\n" +
+		"  \toutput file\n" +
+		"  -d, --duration time.Duration\n" +
+		"  \ttimeout (default value: 1s)\n"
+	expect(t, buf.String(), expected)
+}
+
+func TestFlagUsage(t *testing.T) {
+	fs := NewFlagSet("foo", "", ContinueOnError)
+	fs.NegatableBool("a", "flag a")
+	fs.IntList("c", []int{1, 2, 3}, "flag c\nis multiline")
+	fs.DurationP("duration", "d", time.Second, "flag d")
+
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	fs.PrintDefaults()
+
+	expected := "  -c list of int\n" +
+		"  \tflag c\n" +
+		"  \tis multiline (default value: [1, 2, 3])\n"
+	expect(t, fs.FlagUsage("c"), expected)
+	expect(t, strings.Contains(buf.String(), expected), true)
+	expect(t, fs.FlagUsage("a")+fs.FlagUsage("c")+fs.FlagUsage("duration"), buf.String())
+	expect(t, fs.FlagUsage("undefined"), "")
+}
+
+func TestGetoptUsage(t *testing.T) {
+	fs := NewFlagSet("tool", "", ContinueOnError)
+	fs.Bool("v", "verbose output")
+	fs.String("o", "", "write output to `file`")
+	fs.StringP("name", "n", "", "name of the thing")
+	fs.IntList("include-dir", nil, "")
+	fs.String("secret", "", "")
+	fs.String("token", "", "")
+	fs.Lookup("token").Required = true
+	fs.Lookup("secret").SourceOnly = true
+	fs.Variadic("src", 1, "")
+	fs.Positional("dst", "")
+	fs.SubCommand("run", "")
+
+	expected := "usage: tool [-v] [-o file] [-n|--name NAME] [--include-dir INCLUDE_DIR]... " +
+		"--token TOKEN src... dst [command]"
+	expect(t, fs.GetoptUsage(), expected)
+
+	fs = NewFlagSet("", "", ContinueOnError)
+	fs.Variadic("args", 0, "")
+	expect(t, fs.GetoptUsage(), "usage: [args...]")
+}
+
 func TestErrorHandling(t *testing.T) {
 	t.Run("ContinueOnError", func(t *testing.T) {
 		var buf bytes.Buffer
@@ -0,0 +1,106 @@
+package flagga
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GenMarkdown writes the documentation of the flag set in markdown to the
+// given writer. It contains a table with the name, type, default value,
+// environment variables and description of every flag that can be given in
+// the command line, followed by the documentation of the subcommands, if any.
+func (fs *FlagSet) GenMarkdown(w io.Writer) error {
+	ew := &errWriter{w: w}
+	fs.genMarkdown(ew, "#", fs.name)
+	return ew.err
+}
+
+func (fs *FlagSet) genMarkdown(w io.Writer, heading, title string) {
+	if title != "" {
+		fmt.Fprintf(w, "%s %s\n\n", heading, title)
+	}
+
+	if fs.description != "" {
+		fmt.Fprintf(w, "%s\n\n", fs.description)
+	}
+
+	var flags []*Flag
+	for _, name := range fs.flagOrder {
+		if f := fs.flags[name]; !f.SourceOnly {
+			flags = append(flags, f)
+		}
+	}
+
+	if len(flags) > 0 {
+		fmt.Fprint(w, "| Flag | Type | Default | Environment variable | Description |\n")
+		fmt.Fprint(w, "| --- | --- | --- | --- | --- |\n")
+		for _, f := range flags {
+			var def string
+			if fs.showsDefault(f) {
+				def = markdownCode(prettyValue(f.Default))
+			}
+
+			var envs []string
+			for _, e := range f.Extractors {
+				switch e := e.(type) {
+				case kindExtractor:
+					if e.kind == EnvKind {
+						envs = append(envs, markdownCode(e.key))
+					}
+				case prefixedEnvExtractor:
+					envs = append(envs, markdownCode(e.prefix+e.key))
+				}
+			}
+
+			fmt.Fprintf(
+				w, "| %s | %s | %s | %s | %s |\n",
+				markdownCode(usageName(f)),
+				markdownCell(fs.typeName(f)),
+				def,
+				strings.Join(envs, ", "),
+				markdownCell(f.Usage),
+			)
+		}
+		fmt.Fprint(w, "\n")
+	}
+
+	if len(fs.commandOrder) > 0 {
+		fmt.Fprintf(w, "%s# Subcommands\n\n", heading)
+		for _, name := range fs.commandOrder {
+			prefix := title
+			if prefix != "" {
+				prefix += " "
+			}
+			fs.commands[name].genMarkdown(w, heading+"##", prefix+name)
+		}
+	}
+}
+
+// markdownCell escapes the given text to be used in a table cell.
+func markdownCell(s string) string {
+	s = strings.Replace(s, "|", "\\|", -1)
+	return strings.Replace(s, "\n", "<br>", -1)
+}
+
+// markdownCode formats the given text as inline code in a table cell.
+func markdownCode(s string) string {
+	return "`" + markdownCell(s) + "`"
+}
+
+// errWriter is a writer that keeps the first error found writing to the
+// underlying writer and ignores any write after it.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *errWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	var n int
+	n, w.err = w.w.Write(p)
+	return n, w.err
+}
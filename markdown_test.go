@@ -0,0 +1,53 @@
+package flagga
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestGenMarkdown(t *testing.T) {
+	fs := NewFlagSet("app", "does things", ContinueOnError)
+	fs.Bool("a", "flag a", Env("APP_A"))
+	fs.String("b", "", "flag | b\nmultiline")
+	fs.IntList("c", []int{1, 2}, "flag c", EnvWithPrefix("APP_", "C"), JSON("c"))
+	fs.String("secret", "", "secret", Env("APP_SECRET"))
+	fs.SourceOnly("secret")
+	cmd := fs.SubCommand("run", "runs things")
+	cmd.Duration("timeout", 0, "timeout")
+	cmd.String("token", "", "token")
+	cmd.SourceOnly("token")
+
+	var buf bytes.Buffer
+	expect(t, fs.GenMarkdown(&buf), nil)
+
+	expected := "# app\n\n" +
+		"does things\n\n" +
+		"| Flag | Type | Default | Environment variable | Description |\n" +
+		"| --- | --- | --- | --- | --- |\n" +
+		"| `-a` | bool | `false` | `APP_A` | flag a |\n" +
+		"| `-b` | string |  |  | flag \\| b<br>multiline |\n" +
+		"| `-c` | list of int | `[1, 2]` | `APP_C` | flag c |\n" +
+		"\n" +
+		"## Subcommands\n\n" +
+		"### app run\n\n" +
+		"runs things\n\n" +
+		"| Flag | Type | Default | Environment variable | Description |\n" +
+		"| --- | --- | --- | --- | --- |\n" +
+		"| `-timeout` | time.Duration | `0s` |  | timeout |\n" +
+		"\n"
+
+	expect(t, buf.String(), expected)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, fmt.Errorf("write failed")
+}
+
+func TestGenMarkdownError(t *testing.T) {
+	var fs FlagSet
+	fs.Bool("a", "")
+	expect(t, fs.GenMarkdown(failingWriter{}), fmt.Errorf("write failed"))
+}
@@ -0,0 +1,165 @@
+package flagga
+
+// Messages contains the templates of the messages shown to the user when a
+// flag set is parsed or its usage is printed. Templates are formatted using
+// the fmt package with the arguments described for each one of them.
+type Messages struct {
+	// InvalidSyntax is the error of a malformed flag. It receives the
+	// argument as given in the command line.
+	InvalidSyntax string
+	// UnknownFlag is the error of a flag that is not defined. It receives
+	// the name of the flag.
+	UnknownFlag string
+	// ExpectingValue is the error of a flag given without a value. It
+	// receives the name of the flag.
+	ExpectingValue string
+	// InvalidUTF8 is the error of a value that is not valid UTF-8 given to
+	// a flag that must be UTF-8. It receives the name of the flag.
+	InvalidUTF8 string
+	// UnknownSubCommand is the error of a subcommand that is not defined
+	// when subcommands are required. It receives the name of the subcommand.
+	UnknownSubCommand string
+	// AmbiguousSubCommand is the error of an abbreviated subcommand that
+	// is the prefix of more than one subcommand. It receives the given name
+	// and the names of the matching subcommands separated by commas.
+	AmbiguousSubCommand string
+	// MissingSubCommand is the error of a missing subcommand when
+	// subcommands are required.
+	MissingSubCommand string
+	// MissingRequired is the error of required flags that were not given.
+	// It receives the names of the flags separated by commas.
+	MissingRequired string
+	// AllOrNone is the error of a group of flags that must be given all
+	// together or not at all when only some of them are given. It receives
+	// the names of the flags of the group separated by commas.
+	AllOrNone string
+	// NotOverridden is the error of a flag that must be overridden when it
+	// has its default value. It receives the name of the flag and its
+	// default value.
+	NotOverridden string
+	// FlagAndPositional is the error of a flag given both as a flag and as
+	// a positional argument. It receives the name of the flag and the index
+	// of the positional argument.
+	FlagAndPositional string
+	// ArgCount is the error of a wrong number of positional arguments when
+	// they are declared. It receives the expected and the given number of
+	// arguments.
+	ArgCount string
+	// MinArgCount is the error of too few positional arguments when a
+	// variadic one is declared. It receives the minimum and the given number
+	// of arguments.
+	MinArgCount string
+	// InvalidDefault is the error of a default value given to ApplyDefaults
+	// that can't be set to its flag. It receives the name of the flag and
+	// the error setting it.
+	InvalidDefault string
+	// InvalidTemplate is the error of a template of a flag that can't be
+	// parsed or rendered. It receives the name of the flag and the error.
+	InvalidTemplate string
+	// UndefinedFlag is the error of a template using the value of a flag
+	// that is not defined. It receives the name of the flag.
+	UndefinedFlag string
+	// CyclicReference is the error of flags referencing each other in their
+	// values when references are resolved. It receives the names of the
+	// flags in the cycle separated by arrows.
+	CyclicReference string
+	// Deprecated is the warning printed when a deprecated alias of a flag
+	// is given. It receives the deprecated name and the name of the flag.
+	Deprecated string
+	// AdjacentPositional is the warning printed when a flag with an inline
+	// value is followed by a positional argument and SetWarnAdjacentPositional
+	// is enabled. It receives the flag name, its value and the argument.
+	AdjacentPositional string
+	// Prompt asks the user for the value of a missing required flag when
+	// the flag set is interactive. It receives the usage of the flag or its
+	// name if it has no usage.
+	Prompt string
+	// Usage is the header of the usage of a flag set without name.
+	Usage string
+	// UsageOf is the header of the usage of a named flag set. It receives
+	// the name of the flag set.
+	UsageOf string
+	// ListOf is the type of the list flags in the usage. It receives the
+	// type of the elements of the list.
+	ListOf string
+	// DefaultValue follows the usage of the flags with a default value. It
+	// receives the default value.
+	DefaultValue string
+	// SubCommands is the header of the list of subcommands in the usage.
+	SubCommands string
+}
+
+// DefaultMessages are the messages used by a flag set unless others are set
+// with SetMessages.
+var DefaultMessages = Messages{
+	InvalidSyntax:       "invalid flag syntax: %s",
+	UnknownFlag:         "unknown flag %s",
+	ExpectingValue:      "expecting value for flag: %s",
+	InvalidUTF8:         "invalid UTF-8 value for flag %s",
+	UnknownSubCommand:   "unknown subcommand %s",
+	MissingSubCommand:   "missing subcommand",
+	AmbiguousSubCommand: "ambiguous subcommand %s, could be: %s",
+	MissingRequired:     "missing required flags: %s",
+	AllOrNone:           "flags %s must be given all together or not at all",
+	NotOverridden:       "flag %s must be set to a value other than its default %s",
+	FlagAndPositional:   "flag %s can't be given along with argument %d",
+	ArgCount:            "expecting %d arguments, got %d",
+	MinArgCount:         "expecting at least %d arguments, got %d",
+	InvalidDefault:      "invalid default value for flag %s: %s",
+	InvalidTemplate:     "invalid template for flag %s: %s",
+	UndefinedFlag:       "undefined flag: %s",
+	CyclicReference:     "cyclic reference between flags: %s",
+	Deprecated:          "flag %s is deprecated, use %s instead",
+	AdjacentPositional:  "flag %s was given the value %q followed by the argument %q, quote the value if they are meant to be a single value",
+	Prompt:              "%s: ",
+	Usage:               "Usage:",
+	UsageOf:             "Usage of %s:",
+	ListOf:              "list of %s",
+	DefaultValue:        "(default value: %s)",
+	SubCommands:         "Subcommands:",
+}
+
+// SetMessages sets the messages used by the flag set when it's parsed or its
+// usage is printed. Empty messages will be replaced by the ones in
+// DefaultMessages.
+func (fs *FlagSet) SetMessages(m Messages) {
+	withDefault := func(msg *string, def string) {
+		if *msg == "" {
+			*msg = def
+		}
+	}
+
+	withDefault(&m.InvalidSyntax, DefaultMessages.InvalidSyntax)
+	withDefault(&m.UnknownFlag, DefaultMessages.UnknownFlag)
+	withDefault(&m.ExpectingValue, DefaultMessages.ExpectingValue)
+	withDefault(&m.InvalidUTF8, DefaultMessages.InvalidUTF8)
+	withDefault(&m.UnknownSubCommand, DefaultMessages.UnknownSubCommand)
+	withDefault(&m.MissingSubCommand, DefaultMessages.MissingSubCommand)
+	withDefault(&m.AmbiguousSubCommand, DefaultMessages.AmbiguousSubCommand)
+	withDefault(&m.MissingRequired, DefaultMessages.MissingRequired)
+	withDefault(&m.AllOrNone, DefaultMessages.AllOrNone)
+	withDefault(&m.NotOverridden, DefaultMessages.NotOverridden)
+	withDefault(&m.FlagAndPositional, DefaultMessages.FlagAndPositional)
+	withDefault(&m.ArgCount, DefaultMessages.ArgCount)
+	withDefault(&m.MinArgCount, DefaultMessages.MinArgCount)
+	withDefault(&m.InvalidDefault, DefaultMessages.InvalidDefault)
+	withDefault(&m.InvalidTemplate, DefaultMessages.InvalidTemplate)
+	withDefault(&m.UndefinedFlag, DefaultMessages.UndefinedFlag)
+	withDefault(&m.CyclicReference, DefaultMessages.CyclicReference)
+	withDefault(&m.Deprecated, DefaultMessages.Deprecated)
+	withDefault(&m.AdjacentPositional, DefaultMessages.AdjacentPositional)
+	withDefault(&m.Prompt, DefaultMessages.Prompt)
+	withDefault(&m.Usage, DefaultMessages.Usage)
+	withDefault(&m.UsageOf, DefaultMessages.UsageOf)
+	withDefault(&m.ListOf, DefaultMessages.ListOf)
+	withDefault(&m.DefaultValue, DefaultMessages.DefaultValue)
+	withDefault(&m.SubCommands, DefaultMessages.SubCommands)
+	fs.messages = &m
+}
+
+func (fs *FlagSet) msgs() *Messages {
+	if fs.messages == nil {
+		return &DefaultMessages
+	}
+	return fs.messages
+}
@@ -0,0 +1,48 @@
+package flagga
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestSetMessages(t *testing.T) {
+	fs := NewFlagSet("foo", "", ContinueOnError)
+	fs.SetMessages(Messages{
+		InvalidSyntax:  "sintaxis no válida: %s",
+		UnknownFlag:    "opción desconocida: %s",
+		ExpectingValue: "se esperaba un valor para: %s",
+		UsageOf:        "Uso de %s:",
+		ListOf:         "lista de %s",
+		DefaultValue:   "(por defecto: %s)",
+	})
+	fs.IntList("a", []int{1}, "opción a")
+	fs.String("b", "", "opción b")
+
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	fs.printUsage()
+
+	expected := "Uso de foo:\n\n" +
+		"  -a lista de int\n" +
+		"  \topción a (por defecto: [1])\n" +
+		"  -b string\n" +
+		"  \topción b\n"
+	expect(t, buf.String(), expected)
+
+	_, err := fs.parseNext([]string{"-x"})
+	expect(t, err, fmt.Errorf("opción desconocida: x"))
+
+	_, err = fs.parseNext([]string{"-b"})
+	expect(t, err, fmt.Errorf("se esperaba un valor para: b"))
+
+	_, err = fs.parseNext([]string{"---b"})
+	expect(t, err, fmt.Errorf("sintaxis no válida: ---b"))
+
+	buf.Reset()
+	fs = NewFlagSet("", "", ContinueOnError)
+	fs.SetOutput(&buf)
+	fs.SetMessages(Messages{UnknownFlag: "opción desconocida: %s"})
+	fs.printUsage()
+	expect(t, buf.String(), "Usage:\n\n")
+}
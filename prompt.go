@@ -0,0 +1,87 @@
+package flagga
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SetInteractive sets whether the required flags that are missing once the
+// flag set is filled are asked to the user in the standard input, showing
+// their usage, instead of making parsing fail. The user is only asked if the
+// standard input is a terminal, and secret flags are read without echoing
+// them.
+func (fs *FlagSet) SetInteractive(interactive bool) { fs.interactive = interactive }
+
+var (
+	stdin      io.Reader = os.Stdin
+	isTerminal           = stdinIsTerminal
+	echoOff              = disableEcho
+)
+
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// prompt asks the user for the values of the given missing flags and returns
+// the ones that are still missing because no value was given.
+func (fs *FlagSet) prompt(missing []string) ([]string, error) {
+	if !fs.interactive || len(missing) == 0 || !isTerminal() {
+		return missing, nil
+	}
+
+	var stillMissing []string
+	r := bufio.NewReader(stdin)
+	for _, name := range missing {
+		f := fs.flags[name]
+		text := f.Usage
+		if text == "" {
+			text = name
+		}
+		fmt.Fprintf(fs.Output(), fs.msgs().Prompt, text)
+
+		line, err := readLine(r, f.Secret)
+		if f.Secret {
+			fmt.Fprintln(fs.Output())
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if line == "" {
+			stillMissing = append(stillMissing, name)
+			continue
+		}
+
+		clearList(f.Value)
+		fs.setOrigin(name, OriginArgs)
+		if err := fs.setFlag(f, line); err != nil {
+			return nil, err
+		}
+		fs.audit(f, OriginArgs)
+	}
+
+	return stillMissing, nil
+}
+
+// readLine reads a line from the given reader, without echoing it in the
+// terminal if it's secret.
+func readLine(r *bufio.Reader, secret bool) (string, error) {
+	if secret {
+		restore, err := echoOff()
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
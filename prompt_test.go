@@ -0,0 +1,80 @@
+package flagga
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestInteractive(t *testing.T) {
+	var echoDisabled, echoRestored int
+	defer func(r func() bool, e func() (func(), error), in io.Reader) {
+		isTerminal, echoOff, stdin = r, e, in
+	}(isTerminal, echoOff, stdin)
+
+	isTerminal = func() bool { return true }
+	echoOff = func() (func(), error) {
+		echoDisabled++
+		return func() { echoRestored++ }, nil
+	}
+	stdin = strings.NewReader("alice\ns3cr3t\n")
+
+	var buf bytes.Buffer
+	fs := NewFlagSet("", "", ContinueOnError)
+	fs.SetOutput(&buf)
+	fs.SetInteractive(true)
+	user := fs.String("user", "", "name of the user")
+	password := fs.String("password", "", "")
+	host := fs.String("host", "localhost", "")
+	for _, name := range []string{"user", "password", "host"} {
+		fs.Lookup(name).Required = true
+	}
+	fs.Lookup("password").Secret = true
+
+	expect(t, fs.Parse([]string{"-host", "example.com"}), nil)
+	expect(t, *user, "alice")
+	expect(t, *password, "s3cr3t")
+	expect(t, *host, "example.com")
+	expect(t, buf.String(), "name of the user: password: \n")
+	expect(t, echoDisabled, 1)
+	expect(t, echoRestored, 1)
+}
+
+func TestInteractiveNoInput(t *testing.T) {
+	defer func(r func() bool, in io.Reader) {
+		isTerminal, stdin = r, in
+	}(isTerminal, stdin)
+
+	isTerminal = func() bool { return true }
+	stdin = strings.NewReader("\n")
+
+	fs := NewFlagSet("", "", ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.SetInteractive(true)
+	fs.String("a", "", "")
+	fs.String("b", "", "")
+	fs.Lookup("a").Required = true
+	fs.Lookup("b").Required = true
+
+	expect(t, fs.Parse(nil), fmt.Errorf("missing required flags: a, b"))
+}
+
+func TestInteractiveNotTerminal(t *testing.T) {
+	defer func(r func() bool, in io.Reader) {
+		isTerminal, stdin = r, in
+	}(isTerminal, stdin)
+
+	isTerminal = func() bool { return false }
+	stdin = strings.NewReader("foo\n")
+
+	fs := NewFlagSet("", "", ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.SetInteractive(true)
+	fs.String("a", "", "")
+	fs.Lookup("a").Required = true
+
+	expect(t, fs.Parse(nil), fmt.Errorf("missing required flags: a"))
+}
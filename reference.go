@@ -0,0 +1,113 @@
+package flagga
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var referenceRegexp = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// SetResolveReferences sets whether the references to other flags in the
+// values of string and string list flags, such as ${data-dir}/app.log, are
+// replaced by the values of the referenced flags once all the flags are
+// filled. References to undefined flags are kept as they are, and cyclic
+// references make the parsing fail.
+func (fs *FlagSet) SetResolveReferences(resolve bool) { fs.resolveRefs = resolve }
+
+// resolveReferences replaces the references to other flags in the values of
+// the string and string list flags, resolving the referenced flags first.
+func (fs *FlagSet) resolveReferences() error {
+	r := referenceResolver{fs: fs, state: make(map[string]int)}
+	for _, name := range fs.flagOrder {
+		if err := r.resolve(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	refResolving = iota + 1
+	refResolved
+)
+
+type referenceResolver struct {
+	fs    *FlagSet
+	state map[string]int
+	path  []string
+}
+
+func (r *referenceResolver) resolve(name string) error {
+	switch r.state[name] {
+	case refResolved:
+		return nil
+	case refResolving:
+		path := append(r.path, name)
+		for i, n := range path {
+			if n == name {
+				path = path[i:]
+				break
+			}
+		}
+		return fmt.Errorf(r.fs.msgs().CyclicReference, strings.Join(path, " -> "))
+	}
+
+	v, ok := r.fs.flags[name].Value.(*value)
+	if !ok {
+		r.state[name] = refResolved
+		return nil
+	}
+
+	r.state[name] = refResolving
+	r.path = append(r.path, name)
+	defer func() {
+		r.path = r.path[:len(r.path)-1]
+		r.state[name] = refResolved
+	}()
+
+	switch dst := v.value.(type) {
+	case *string:
+		s, err := r.replace(*dst)
+		if err != nil {
+			return err
+		}
+		*dst = s
+	case *[]string:
+		// the list may share its elements with the default value
+		var values = make([]string, len(*dst))
+		for i, s := range *dst {
+			s, err := r.replace(s)
+			if err != nil {
+				return err
+			}
+			values[i] = s
+		}
+		*dst = values
+	}
+
+	return nil
+}
+
+// replace replaces the references to defined flags in the given string.
+func (r *referenceResolver) replace(s string) (string, error) {
+	var err error
+	result := referenceRegexp.ReplaceAllStringFunc(s, func(ref string) string {
+		name := r.fs.normalizeName(ref[2 : len(ref)-1])
+		f, ok := r.fs.flags[name]
+		if !ok || err != nil {
+			return ref
+		}
+
+		if err = r.resolve(name); err != nil {
+			return ref
+		}
+
+		if g, ok := f.Value.(Getter); ok {
+			return fmt.Sprint(g.Get())
+		}
+		return fmt.Sprint(f.Value)
+	})
+
+	return result, err
+}
@@ -0,0 +1,50 @@
+package flagga
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestResolveReferences(t *testing.T) {
+	var fs FlagSet
+	fs.SetResolveReferences(true)
+	logFile := fs.String("log-file", "${data-dir}/app.log", "")
+	dataDir := fs.String("data-dir", "${root}/data", "")
+	root := fs.String("root", "/var", "")
+	port := fs.Int("port", 8080, "")
+	urls := fs.StringList("urls", []string{"http://localhost:${port}", "${undefined}"}, "")
+
+	err := fs.Parse([]string{"-root", "/srv"})
+	expect(t, err, nil)
+	expect(t, *root, "/srv")
+	expect(t, *dataDir, "/srv/data")
+	expect(t, *logFile, "/srv/data/app.log")
+	expect(t, *port, 8080)
+	expect(t, *urls, []string{"http://localhost:8080", "${undefined}"})
+	expect(t, fs.Lookup("urls").Default, []string{"http://localhost:${port}", "${undefined}"})
+}
+
+func TestResolveReferencesDisabled(t *testing.T) {
+	var fs FlagSet
+	logFile := fs.String("log-file", "${data-dir}/app.log", "")
+	fs.String("data-dir", "/data", "")
+
+	expect(t, fs.Parse(nil), nil)
+	expect(t, *logFile, "${data-dir}/app.log")
+}
+
+func TestResolveReferencesCycle(t *testing.T) {
+	var buf bytes.Buffer
+	fs := NewFlagSet("", "", ContinueOnError)
+	fs.SetOutput(&buf)
+	fs.SetResolveReferences(true)
+	fs.String("a", "${b}", "")
+	fs.String("b", "x${c}", "")
+	fs.String("c", "${b}", "")
+
+	err := fs.Parse(nil)
+	expect(t, err, fmt.Errorf("cyclic reference between flags: b -> c -> b"))
+	expect(t, strings.HasPrefix(buf.String(), err.Error()), true)
+}
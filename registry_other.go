@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package flagga
+
+type registrySource struct{}
+
+// RegistryVia returns a Source that will provide the values under the given
+// key of the Windows registry. On other platforms the source provides no
+// values.
+func RegistryVia(root, path string) Source {
+	return registrySource{}
+}
+
+func (registrySource) Kind() string                    { return RegistryKind }
+func (registrySource) Open() error                     { return nil }
+func (registrySource) Close() error                    { return nil }
+func (registrySource) Get(string, Value) (bool, error) { return false, nil }
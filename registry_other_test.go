@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package flagga
+
+import "testing"
+
+func TestRegistryVia(t *testing.T) {
+	var fs FlagSet
+	name := fs.String("name", "default", "", Registry("name"))
+
+	err := fs.Parse(nil, RegistryVia("HKCU", `Software\flagga-test`))
+	expect(t, err, nil)
+	expect(t, *name, "default")
+}
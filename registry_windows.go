@@ -0,0 +1,143 @@
+//go:build windows
+// +build windows
+
+package flagga
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"syscall"
+	"unicode/utf16"
+)
+
+var registryRoots = map[string]syscall.Handle{
+	"HKEY_CLASSES_ROOT":   syscall.HKEY_CLASSES_ROOT,
+	"HKCR":                syscall.HKEY_CLASSES_ROOT,
+	"HKEY_CURRENT_USER":   syscall.HKEY_CURRENT_USER,
+	"HKCU":                syscall.HKEY_CURRENT_USER,
+	"HKEY_LOCAL_MACHINE":  syscall.HKEY_LOCAL_MACHINE,
+	"HKLM":                syscall.HKEY_LOCAL_MACHINE,
+	"HKEY_USERS":          syscall.HKEY_USERS,
+	"HKU":                 syscall.HKEY_USERS,
+	"HKEY_CURRENT_CONFIG": syscall.HKEY_CURRENT_CONFIG,
+	"HKCC":                syscall.HKEY_CURRENT_CONFIG,
+}
+
+type registrySource struct {
+	root string
+	path string
+	key  syscall.Handle
+	open bool
+}
+
+// RegistryVia returns a Source that will provide the values under the given
+// key of the Windows registry. The root is the name of a predefined key, such
+// as HKEY_LOCAL_MACHINE or its abbreviation HKLM, and the path is the path of
+// the key inside of it. Values can be extracted using Registry. String values
+// are provided as strings, DWORD and QWORD values as uint64, multi-string
+// values as lists of strings and binary values as bytes.
+func RegistryVia(root, path string) Source {
+	return &registrySource{root: root, path: path}
+}
+
+func (*registrySource) Kind() string { return RegistryKind }
+
+func (s *registrySource) Open() error {
+	root, ok := registryRoots[strings.ToUpper(s.root)]
+	if !ok {
+		return fmt.Errorf("unknown registry root: %s", s.root)
+	}
+
+	path, err := syscall.UTF16PtrFromString(s.path)
+	if err != nil {
+		return err
+	}
+
+	if err := syscall.RegOpenKeyEx(root, path, 0, syscall.KEY_READ, &s.key); err != nil {
+		return fmt.Errorf("unable to open registry key %s\\%s: %s", s.root, s.path, err)
+	}
+
+	s.open = true
+	return nil
+}
+
+func (s *registrySource) Close() error {
+	if !s.open {
+		return nil
+	}
+
+	s.open = false
+	return syscall.RegCloseKey(s.key)
+}
+
+func (s *registrySource) Get(key string, dst Value) (bool, error) {
+	if !s.open {
+		return false, nil
+	}
+
+	name, err := syscall.UTF16PtrFromString(key)
+	if err != nil {
+		return false, err
+	}
+
+	var typ, n uint32
+	err = syscall.RegQueryValueEx(s.key, name, nil, &typ, nil, &n)
+	if err == syscall.ERROR_FILE_NOT_FOUND {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	var buf = make([]byte, n)
+	if n > 0 {
+		err = syscall.RegQueryValueEx(s.key, name, nil, &typ, &buf[0], &n)
+		if err != nil {
+			return false, err
+		}
+		buf = buf[:n]
+	}
+
+	var val interface{}
+	switch typ {
+	case syscall.REG_SZ, syscall.REG_EXPAND_SZ:
+		val = utf16BytesToString(buf)
+	case syscall.REG_MULTI_SZ:
+		var values = []string{}
+		for _, v := range strings.Split(utf16BytesToString(buf), "\x00") {
+			if v != "" {
+				values = append(values, v)
+			}
+		}
+		val = values
+	case syscall.REG_DWORD:
+		if len(buf) < 4 {
+			return false, fmt.Errorf("invalid registry DWORD value %s", key)
+		}
+		val = uint64(binary.LittleEndian.Uint32(buf))
+	case syscall.REG_QWORD:
+		if len(buf) < 8 {
+			return false, fmt.Errorf("invalid registry QWORD value %s", key)
+		}
+		val = binary.LittleEndian.Uint64(buf)
+	default:
+		val = buf
+	}
+
+	if err := dst.Set(val); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// utf16BytesToString converts the given UTF-16 bytes to a string, keeping
+// the null characters separating the strings of multi-string values but
+// removing the trailing ones.
+func utf16BytesToString(buf []byte) string {
+	var u = make([]uint16, len(buf)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(buf[i*2:])
+	}
+	return strings.TrimRight(string(utf16.Decode(u)), "\x00")
+}
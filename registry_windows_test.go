@@ -0,0 +1,86 @@
+//go:build windows
+// +build windows
+
+package flagga
+
+import (
+	"encoding/binary"
+	"syscall"
+	"testing"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var (
+	advapi32           = syscall.NewLazyDLL("advapi32.dll")
+	procRegCreateKeyEx = advapi32.NewProc("RegCreateKeyExW")
+	procRegSetValueEx  = advapi32.NewProc("RegSetValueExW")
+	procRegDeleteKey   = advapi32.NewProc("RegDeleteKeyW")
+)
+
+const testRegistryPath = `Software\flagga-test`
+
+func setRegistryValue(t *testing.T, key syscall.Handle, name string, typ uint32, data []byte) {
+	namep, _ := syscall.UTF16PtrFromString(name)
+	r, _, _ := procRegSetValueEx.Call(
+		uintptr(key),
+		uintptr(unsafe.Pointer(namep)),
+		0,
+		uintptr(typ),
+		uintptr(unsafe.Pointer(&data[0])),
+		uintptr(len(data)),
+	)
+	if r != 0 {
+		t.Fatalf("unable to set registry value %s: %s", name, syscall.Errno(r))
+	}
+}
+
+func utf16Bytes(s string) []byte {
+	u := utf16.Encode([]rune(s + "\x00"))
+	var buf = make([]byte, len(u)*2)
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(buf[i*2:], c)
+	}
+	return buf
+}
+
+func TestRegistryVia(t *testing.T) {
+	path, _ := syscall.UTF16PtrFromString(testRegistryPath)
+	var key syscall.Handle
+	r, _, _ := procRegCreateKeyEx.Call(
+		uintptr(syscall.HKEY_CURRENT_USER),
+		uintptr(unsafe.Pointer(path)),
+		0, 0, 0,
+		uintptr(syscall.KEY_ALL_ACCESS),
+		0,
+		uintptr(unsafe.Pointer(&key)),
+		0,
+	)
+	if r != 0 {
+		t.Skipf("unable to create test registry key: %s", syscall.Errno(r))
+	}
+	defer procRegDeleteKey.Call(uintptr(syscall.HKEY_CURRENT_USER), uintptr(unsafe.Pointer(path)))
+	defer syscall.RegCloseKey(key)
+
+	var dword = make([]byte, 4)
+	binary.LittleEndian.PutUint32(dword, 42)
+	setRegistryValue(t, key, "name", syscall.REG_SZ, utf16Bytes("foo"))
+	setRegistryValue(t, key, "port", syscall.REG_DWORD, dword)
+	setRegistryValue(t, key, "tags", syscall.REG_MULTI_SZ, append(utf16Bytes("a\x00b"), 0, 0))
+
+	var fs FlagSet
+	name := fs.String("name", "", "", Registry("name"))
+	port := fs.Int("port", 0, "", Registry("port"))
+	tags := fs.StringList("tags", nil, "", Registry("tags"))
+	missing := fs.String("missing", "default", "", Registry("missing"))
+
+	err := fs.Parse(nil, RegistryVia("HKCU", testRegistryPath))
+	expect(t, err, nil)
+	expect(t, *name, "foo")
+	expect(t, *port, 42)
+	expect(t, *tags, []string{"a", "b"})
+	expect(t, *missing, "default")
+
+	err = RegistryVia("HKFOO", testRegistryPath).Open()
+	expect(t, err.Error(), "unknown registry root: HKFOO")
+}
@@ -1,9 +1,19 @@
 package flagga
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 // Source provides values for the flags.
@@ -18,6 +28,51 @@ type Source interface {
 	Close() error
 }
 
+// ContextSource is a Source that can be opened with a context, so opening it
+// can be cancelled or time out, such as a source fetching the configuration
+// from a remote service.
+type ContextSource interface {
+	Source
+	// OpenContext is like Open, but it must give up when the context is done.
+	OpenContext(ctx context.Context) error
+}
+
+// ErrSourceTimeout is returned when opening a source takes longer than the
+// timeout set with SetSourceTimeout.
+var ErrSourceTimeout = fmt.Errorf("timeout opening source")
+
+// KindedSource is a Source that declares the kind of values it provides.
+// Extractors use the kind of the sources to find the ones they can get values
+// from.
+type KindedSource interface {
+	Source
+	// Kind returns the kind of the source.
+	Kind() string
+}
+
+const (
+	// EnvKind is the kind of the sources providing environment variables.
+	EnvKind = "env"
+	// JSONKind is the kind of the sources providing the values of a JSON.
+	JSONKind = "json"
+	// YAMLKind is the kind of the sources providing the values of a YAML.
+	YAMLKind = "yaml"
+	// TOMLKind is the kind of the sources providing the values of a TOML.
+	TOMLKind = "toml"
+	// RegistryKind is the kind of the sources providing the values of a key
+	// of the Windows registry.
+	RegistryKind = "registry"
+)
+
+// KindOf returns the kind of the given source or an empty string if the
+// source does not declare any.
+func KindOf(s Source) string {
+	if ks, ok := s.(KindedSource); ok {
+		return ks.Kind()
+	}
+	return ""
+}
+
 type envSource string
 
 // EnvPrefix will provide as values the environment variables that match
@@ -28,6 +83,7 @@ func EnvPrefix(prefix string) Source {
 
 func (envSource) Open() error  { return nil }
 func (envSource) Close() error { return nil }
+func (envSource) Kind() string { return EnvKind }
 func (e envSource) Get(key string, dst Value) (bool, error) {
 	v, ok := os.LookupEnv(string(e) + key)
 	if !ok {
@@ -42,11 +98,18 @@ func (e envSource) Get(key string, dst Value) (bool, error) {
 }
 
 // FileSource is a Source that reads a file and parses it using a parser
-// function.
+// function. Gzip-compressed files are decompressed before being parsed.
 type FileSource struct {
 	File   string
 	Parser ParseFunc
 	Value  map[string]interface{}
+	// FS is the filesystem the file is read from. If it's nil, the file is
+	// read from the OS filesystem.
+	FS fs.FS
+	// MaxSize is the maximum size in bytes of the file. Opening the source
+	// fails if the file is bigger, once decompressed. If it's zero, the size
+	// is not limited.
+	MaxSize int64
 }
 
 // ParseFunc is a function that will parse the given data and put the
@@ -56,30 +119,574 @@ type ParseFunc func(data []byte, dst interface{}) error
 // NewFileSource returns a Source that will read the given file and use the
 // given parser to extract the contents of it.
 func NewFileSource(file string, parser ParseFunc) Source {
-	return &FileSource{file, parser, nil}
+	return &FileSource{File: file, Parser: parser}
+}
+
+// FSVia returns a Source that will read the file with the given name from
+// the given filesystem and use the given parser to extract the contents of
+// it. It can be used to read configuration embedded in the binary.
+func FSVia(fsys fs.FS, name string, parser ParseFunc) Source {
+	return &FileSource{File: name, Parser: parser, FS: fsys}
 }
 
 type jsonSource struct {
-	Source
+	*FileSource
 }
 
+func (*jsonSource) Kind() string { return JSONKind }
+
 // JSONVia returns a Source that will use a JSON file as a provider of
 // flag values.
 func JSONVia(file string) Source {
-	return &jsonSource{NewFileSource(file, json.Unmarshal)}
+	return &jsonSource{&FileSource{File: file, Parser: json.Unmarshal}}
+}
+
+// YAMLVia returns a Source that will use a YAML file as a provider of flag
+// values. As flagga has no dependencies, the YAML decoder must be given, such
+// as yaml.Unmarshal of gopkg.in/yaml.v2. If it's nil, the decoder set in the
+// flag set with SetDecoder is used, or opening the source fails if there is
+// none. The maps it decodes with keys of any type are converted to maps with
+// string keys.
+func YAMLVia(file string, unmarshal ParseFunc) Source {
+	return newDecodedSource(file, YAMLKind, "YAML", unmarshal)
+}
+
+// TOMLVia returns a Source that will use a TOML file as a provider of flag
+// values. As flagga has no dependencies, the TOML decoder must be given, such
+// as toml.Unmarshal of github.com/BurntSushi/toml. If it's nil, the decoder
+// set in the flag set with SetDecoder is used, or opening the source fails if
+// there is none. Dates and times are given to the flags as they are decoded,
+// so they can fill time flags, and durations can be given as nanoseconds or
+// as strings such as "1m30s".
+func TOMLVia(file string, unmarshal ParseFunc) Source {
+	return newDecodedSource(file, TOMLKind, "TOML", unmarshal)
+}
+
+// decoderSource is a Source that can take the decoder of its format from the
+// ones set in the flag set with SetDecoder.
+type decoderSource interface {
+	useDecoders(decoders map[string]ParseFunc)
+}
+
+// decodedSource is a FileSource whose format has no decoder in the standard
+// library, so it must be given to the source or set in the flag set.
+type decodedSource struct {
+	*FileSource
+	kind      string
+	format    string
+	unmarshal ParseFunc
+}
+
+func newDecodedSource(file, kind, format string, unmarshal ParseFunc) *decodedSource {
+	s := &decodedSource{
+		FileSource: &FileSource{File: file},
+		kind:       kind,
+		format:     format,
+		unmarshal:  unmarshal,
+	}
+	s.Parser = s.decode
+	return s
+}
+
+func (s *decodedSource) Kind() string { return s.kind }
+
+func (s *decodedSource) useDecoders(decoders map[string]ParseFunc) {
+	if s.unmarshal == nil {
+		s.unmarshal = decoders[s.kind]
+	}
+}
+
+func (s *decodedSource) decode(data []byte, dst interface{}) error {
+	return decodeConfig(s.format, s.unmarshal, data, dst)
+}
+
+// decodeConfig decodes the given config file of the given format with the
+// given function and puts its values in the given map.
+func decodeConfig(format string, unmarshal ParseFunc, data []byte, dst interface{}) error {
+	if unmarshal == nil {
+		return fmt.Errorf("no %s decoder, it must be given to %sVia or set with SetDecoder", format, format)
+	}
+
+	var raw interface{}
+	if err := unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if raw == nil {
+		*dst.(*map[string]interface{}) = map[string]interface{}{}
+		return nil
+	}
+
+	values, ok := normalizeMaps(raw).(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid %s config file: expecting a map, got %T", format, raw)
+	}
+
+	*dst.(*map[string]interface{}) = values
+	return nil
+}
+
+// normalizeMaps converts the maps of the given decoded value, which may have
+// keys of any type, into maps with string keys, and the lists of maps into
+// lists of values, so they can be assigned like the values decoded from a
+// JSON.
+func normalizeMaps(val interface{}) interface{} {
+	switch val := val.(type) {
+	case map[interface{}]interface{}:
+		var m = make(map[string]interface{}, len(val))
+		for k, v := range val {
+			m[fmt.Sprint(k)] = normalizeMaps(v)
+		}
+		return m
+	case map[string]interface{}:
+		var m = make(map[string]interface{}, len(val))
+		for k, v := range val {
+			m[k] = normalizeMaps(v)
+		}
+		return m
+	case []interface{}:
+		var l = make([]interface{}, len(val))
+		for i, v := range val {
+			l[i] = normalizeMaps(v)
+		}
+		return l
+	case []map[string]interface{}:
+		var l = make([]interface{}, len(val))
+		for i, v := range val {
+			l[i] = normalizeMaps(v)
+		}
+		return l
+	default:
+		return val
+	}
+}
+
+var fileFormats = map[string]func(file string) Source{
+	".json": JSONVia,
+	".yaml": yamlFile,
+	".yml":  yamlFile,
+	".toml": tomlFile,
+}
+
+func yamlFile(file string) Source { return YAMLVia(file, nil) }
+func tomlFile(file string) Source { return TOMLVia(file, nil) }
+
+// RegisterFileFormat registers the function that creates the sources for the
+// files with the given extension (e.g. ".ini"), so they can be used with
+// FileVia.
+func RegisterFileFormat(ext string, via func(file string) Source) {
+	fileFormats[strings.ToLower(ext)] = via
+}
+
+// FileVia returns a Source for the given file, choosing its format by the
+// extension of the file. Only JSON, YAML and TOML files and the formats
+// registered with RegisterFileFormat are supported, and the YAML and TOML
+// decoders must be set with SetDecoder. The .gz extension of gzip-compressed
+// files is ignored, so config.json.gz is a JSON file.
+func FileVia(file string) (Source, error) {
+	name := strings.ToLower(file)
+	if filepath.Ext(name) == ".gz" {
+		name = strings.TrimSuffix(name, ".gz")
+	}
+
+	via, ok := fileFormats[filepath.Ext(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported config file format: %s", file)
+	}
+
+	return via(file), nil
+}
+
+// ConfigFromEnv returns a Source for the config file whose path is in the
+// given environment variable, or in defaultPath if the variable is not set,
+// choosing its format by the extension of the file as FileVia does. The file
+// at the default path is optional, so no values are provided if it doesn't
+// exist, but opening the source fails if the file given in the environment
+// variable doesn't exist. No values are provided either if there is no path
+// at all.
+func ConfigFromEnv(envVar, defaultPath string) (Source, error) {
+	path, ok := os.LookupEnv(envVar)
+	if !ok || path == "" {
+		if defaultPath == "" {
+			return &optionalSource{}, nil
+		}
+
+		src, err := FileVia(defaultPath)
+		if err != nil {
+			return nil, err
+		}
+		return Optional(src), nil
+	}
+
+	return FileVia(path)
+}
+
+// StateFileVia returns a Source that will read the values saved with
+// SaveState to the JSON file at the given path, so a program can remember the
+// flags used in its last run. Unlike other sources, it provides the values of
+// all the flags by their name without adding any extractor, but the flags
+// found by their extractors take precedence. If the file doesn't exist, as in
+// the first run, it provides no values.
+func StateFileVia(path string) Source {
+	return &stateSource{Optional(&FileSource{File: path, Parser: json.Unmarshal})}
+}
+
+type stateSource struct {
+	Source
+}
+
+// stateValue gets the value of the flag with the given name from the first
+// state source in the given sources that has it.
+func stateValue(sources []Source, name string, dst Value) (bool, error) {
+	for _, s := range sources {
+		if _, ok := s.(*stateSource); !ok {
+			continue
+		}
+
+		ok, err := s.Get(name, dst)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	return false, nil
+}
+
+// Optional returns a Source that provides no values instead of failing to
+// open if the given source fails to open because its file doesn't exist, so
+// config files can be used only if they are present. Any other error opening
+// the source, such as an invalid file, is still returned.
+func Optional(s Source) Source {
+	if _, ok := s.(configSource); ok {
+		return optionalConfigSource{&optionalSource{s}}
+	}
+	return &optionalSource{s}
+}
+
+type optionalSource struct {
+	src Source
+}
+
+// WithMaxSize sets the maximum size in bytes of the file read by the given
+// source, as the MaxSize of a FileSource does, and returns the source. It
+// can be used with the sources that don't expose their FileSource, such as
+// the ones created by JSONVia, YAMLVia, TOMLVia or FileVia. It panics if the
+// source doesn't read a file.
+func WithMaxSize(s Source, maxSize int64) Source {
+	switch src := s.(type) {
+	case fileBackedSource:
+		src.fileSource().MaxSize = maxSize
+	case *optionalSource:
+		WithMaxSize(src.src, maxSize)
+	case optionalConfigSource:
+		WithMaxSize(src.src, maxSize)
+	default:
+		panic(fmt.Errorf("source of type %T doesn't read a file", s))
+	}
+	return s
+}
+
+// fileBackedSource is a source reading a file with a FileSource.
+type fileBackedSource interface {
+	fileSource() *FileSource
+}
+
+// optionalConfigSource is an optional source of a config file, so it's still
+// matched by the Config extractor.
+type optionalConfigSource struct {
+	*optionalSource
+}
+
+func (optionalConfigSource) configSource() {}
+
+func (s *optionalSource) Kind() string {
+	if s.src == nil {
+		return ""
+	}
+	return KindOf(s.src)
+}
+
+func (s *optionalSource) useDecoders(decoders map[string]ParseFunc) {
+	if ds, ok := s.src.(decoderSource); ok {
+		ds.useDecoders(decoders)
+	}
+}
+
+func (s *optionalSource) Open() error {
+	if s.src == nil {
+		return nil
+	}
+
+	err := s.src.Open()
+	if errors.Is(err, fs.ErrNotExist) {
+		s.src = nil
+		return nil
+	}
+	return err
+}
+
+func (s *optionalSource) Close() error {
+	if s.src == nil {
+		return nil
+	}
+	return s.src.Close()
+}
+
+func (s *optionalSource) Get(key string, dst Value) (bool, error) {
+	if s.src == nil {
+		return false, nil
+	}
+	return s.src.Get(key, dst)
+}
+
+// YAMLWithEnv returns a Source that will read the given YAML file, decoded
+// as YAMLVia does, and overlay on top of it the environment variables with
+// the given prefix when it's opened, so an environment variable takes
+// precedence over the key with the same name in the file. The name of the
+// variable without the prefix is the key, matched case-insensitively with the
+// top-level keys of the file, so with the prefix APP_ the variable
+// APP_DB_HOST overrides the key db_host. Variables not matching any key in
+// the file provide the key with their name as it is, such as DB_HOST.
+func YAMLWithEnv(file, envPrefix string, unmarshal ParseFunc) Source {
+	return &envOverlaySource{newDecodedSource(file, YAMLKind, "YAML", unmarshal), envPrefix}
+}
+
+type envOverlaySource struct {
+	*decodedSource
+	prefix string
+}
+
+func (s *envOverlaySource) Open() error {
+	if err := s.decodedSource.Open(); err != nil {
+		return err
+	}
+
+	var keys = make(map[string]string, len(s.Value))
+	for k := range s.Value {
+		keys[strings.ToLower(k)] = k
+	}
+
+	for _, env := range os.Environ() {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) != 2 || !strings.HasPrefix(kv[0], s.prefix) {
+			continue
+		}
+
+		key := strings.TrimPrefix(kv[0], s.prefix)
+		if key == "" {
+			continue
+		}
+
+		if k, ok := keys[strings.ToLower(key)]; ok {
+			key = k
+		}
+		s.Value[key] = kv[1]
+	}
+
+	return nil
+}
+
+// ConfigTreeVia returns a Source that will provide the content of each file
+// in the given directory tree as the value of a key, as in the configs and
+// secrets mounted as volumes by Kubernetes. Files in nested directories have
+// the path of the directories as a prefix separated by dots, so the content of
+// db/host is the value of db.host. Trailing newlines are removed from the
+// values, and files and directories starting with a dot are ignored.
+func ConfigTreeVia(dir string) Source {
+	return &treeSource{dir: dir}
+}
+
+type treeSource struct {
+	dir   string
+	value map[string]interface{}
+}
+
+func (*treeSource) configSource() {}
+
+func (s *treeSource) Open() error {
+	var values = make(map[string]interface{})
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path == s.dir {
+			return nil
+		}
+
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// mounted files are usually symlinks, so the type of the file they
+		// point to is the one that matters
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		key := strings.Replace(filepath.ToSlash(rel), "/", ".", -1)
+		values[key] = strings.TrimRight(string(content), "\r\n")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.value = values
+	return nil
+}
+
+func (s *treeSource) Close() error {
+	return nil
+}
+
+func (s *treeSource) Get(key string, dst Value) (bool, error) {
+	return getValue(s.value, key, dst)
+}
+
+// configSource is implemented by FileSource and all the sources embedding it,
+// no matter the format of their files.
+type configSource interface {
+	Source
+	configSource()
+}
+
+func (*FileSource) configSource() {}
+
+type envFileSource struct {
+	*FileSource
+}
+
+func (*envFileSource) Kind() string { return EnvKind }
+
+// EnvFileVia returns a Source that will provide the environment variables
+// defined in the given file, following the rules of the EnvironmentFile
+// option of systemd units. Values in the file can be extracted using Env.
+//
+// Each line of the file is a KEY=VALUE assignment. Empty lines and lines
+// starting with # or ; are ignored, and lines ending with a backslash are
+// joined with the next one. Values can be enclosed in single quotes, which are
+// taken literally, or double quotes, in which backslash escapes are
+// supported. Unlike dotenv files, the export keyword, comments after a value
+// and variable references are not supported.
+func EnvFileVia(file string) Source {
+	return &envFileSource{&FileSource{File: file, Parser: parseEnvFile}}
+}
+
+func parseEnvFile(data []byte, dst interface{}) error {
+	var values = make(map[string]interface{})
+	lines := strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n")
+	for i := 0; i < len(lines); i++ {
+		n := i + 1
+		line := strings.TrimSpace(lines[i])
+		for strings.HasSuffix(line, "\\") && i+1 < len(lines) {
+			i++
+			line = strings.TrimSpace(line[:len(line)-1] + lines[i])
+		}
+
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		idx := strings.IndexRune(line, '=')
+		if idx <= 0 || !isEnvKey(strings.TrimSpace(line[:idx])) {
+			return fmt.Errorf("invalid environment file line %d: %s", n, line)
+		}
+
+		key := strings.TrimSpace(line[:idx])
+		values[key] = unquoteEnvValue(strings.TrimSpace(line[idx+1:]))
+	}
+
+	*dst.(*map[string]interface{}) = values
+	return nil
+}
+
+func isEnvKey(key string) bool {
+	for i, c := range key {
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func unquoteEnvValue(v string) string {
+	if len(v) < 2 || (v[0] != '"' && v[0] != '\'') || v[len(v)-1] != v[0] {
+		return v
+	}
+
+	if v[0] == '\'' {
+		return v[1 : len(v)-1]
+	}
+
+	v = v[1 : len(v)-1]
+	var buf = make([]byte, 0, len(v))
+	for i := 0; i < len(v); i++ {
+		if v[i] != '\\' || i+1 == len(v) {
+			buf = append(buf, v[i])
+			continue
+		}
+
+		i++
+		switch v[i] {
+		case 'n':
+			buf = append(buf, '\n')
+		case 't':
+			buf = append(buf, '\t')
+		default:
+			buf = append(buf, v[i])
+		}
+	}
+
+	return string(buf)
 }
 
 // Open implements the Source interface.
 func (s *FileSource) Open() error {
+	var f io.ReadCloser
 	var err error
-	content, err := ioutil.ReadFile(s.File)
+	if s.FS != nil {
+		f, err = s.FS.Open(s.File)
+	} else {
+		f, err = os.Open(s.File)
+	}
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
-	return s.Parser(content, &s.Value)
+	rs := readerSource{r: f, parser: s.Parser, maxSize: s.MaxSize}
+	if err := rs.Open(); err != nil {
+		return err
+	}
+
+	s.Value = rs.value
+	return nil
 }
 
+func (s *FileSource) fileSource() *FileSource { return s }
+
 // Close implements the Source interface.
 func (s *FileSource) Close() error {
 	return nil
@@ -87,9 +694,90 @@ func (s *FileSource) Close() error {
 
 // Get implements the Source interface.
 func (s *FileSource) Get(key string, dst Value) (bool, error) {
-	val, ok := s.Value[key]
-	if !ok {
-		return false, nil
+	return getValue(s.Value, key, dst)
+}
+
+type readerSource struct {
+	r       io.Reader
+	parser  ParseFunc
+	maxSize int64
+	value   map[string]interface{}
+}
+
+// ReaderSource returns a Source that will read all the content of the given
+// reader when it's opened and use the given parser to extract the contents of
+// it, so configuration can be loaded from any stream. Gzip-compressed content
+// is decompressed before being parsed. The reader is not closed by the
+// source.
+func ReaderSource(r io.Reader, parser ParseFunc) Source {
+	return &readerSource{r: r, parser: parser}
+}
+
+// ReaderSourceWithLimit is like ReaderSource, but opening the source fails if
+// the reader has more than maxSize bytes, so untrusted streams can't make the
+// program read an unbounded amount of data.
+func ReaderSourceWithLimit(r io.Reader, parser ParseFunc, maxSize int64) Source {
+	return &readerSource{r: r, parser: parser, maxSize: maxSize}
+}
+
+// ErrMaxSize is returned when opening a source whose content exceeds its
+// maximum size.
+var ErrMaxSize = fmt.Errorf("content exceeds the maximum size of the source")
+
+func (*readerSource) configSource() {}
+
+func (s *readerSource) Open() error {
+	r, err := decompress(s.r)
+	if err != nil {
+		return err
+	}
+
+	if s.maxSize > 0 {
+		r = io.LimitReader(r, s.maxSize+1)
+	}
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if s.maxSize > 0 && int64(len(content)) > s.maxSize {
+		return ErrMaxSize
+	}
+
+	return s.parser(content, &s.value)
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompress returns a reader with the decompressed content of the given
+// reader if it's compressed with gzip, or with its content otherwise.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if !bytes.Equal(magic, gzipMagic) {
+		return br, nil
+	}
+
+	return gzip.NewReader(br)
+}
+
+func (s *readerSource) Close() error {
+	return nil
+}
+
+func (s *readerSource) Get(key string, dst Value) (bool, error) {
+	return getValue(s.value, key, dst)
+}
+
+func getValue(values map[string]interface{}, key string, dst Value) (bool, error) {
+	val, ok, err := lookupKey(values, key)
+	if err != nil || !ok {
+		return false, err
 	}
 
 	if err := dst.Set(val); err != nil {
@@ -98,3 +786,36 @@ func (s *FileSource) Get(key string, dst Value) (bool, error) {
 
 	return true, nil
 }
+
+// lookupKey returns the value of the given key. Keys not found as they are
+// are split by dots to look them up in nested objects, so "db.host" is the
+// host key inside the db object. It's only found if the whole path exists,
+// and an error is returned if any of the parts of the path before the last
+// one is not an object.
+func lookupKey(values map[string]interface{}, key string) (interface{}, bool, error) {
+	if val, ok := values[key]; ok {
+		return val, true, nil
+	}
+
+	parts := strings.Split(key, ".")
+	if len(parts) == 1 {
+		return nil, false, nil
+	}
+
+	for i, part := range parts[:len(parts)-1] {
+		val, ok := values[part]
+		if !ok || val == nil {
+			return nil, false, nil
+		}
+
+		nested, ok := val.(map[string]interface{})
+		if !ok {
+			path := strings.Join(parts[:i+1], ".")
+			return nil, false, fmt.Errorf("invalid key %s: %s is not an object", key, path)
+		}
+		values = nested
+	}
+
+	val, ok := values[parts[len(parts)-1]]
+	return val, ok, nil
+}
@@ -2,12 +2,20 @@ package flagga
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 func TestEnvPrefix(t *testing.T) {
@@ -116,3 +124,874 @@ func TestJSONVia(t *testing.T) {
 		})
 	}
 }
+
+func TestFSVia(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config/default.json": &fstest.MapFile{
+			Data: []byte(`{"foo": "bar", "baz": [1, 2]}`),
+		},
+	}
+
+	source := FSVia(fsys, "config/default.json", json.Unmarshal)
+	if err := source.Open(); err != nil {
+		t.Fatalf("unable to open file: %s", err)
+	}
+
+	var s string
+	ok, err := source.Get("foo", NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, s, "bar")
+
+	var ints []int
+	ok, err = source.Get("baz", NewValue(&ints))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, ints, []int{1, 2})
+
+	ok, err = source.Get("qux", NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, false)
+
+	missing := FSVia(fsys, "config/missing.json", json.Unmarshal)
+	if err := missing.Open(); err == nil {
+		t.Errorf("expecting error opening missing file, got nil instead")
+	}
+}
+
+func TestNestedKeys(t *testing.T) {
+	source := ReaderSource(
+		strings.NewReader(`{
+			"db": {"host": "localhost", "port": 5432},
+			"db.name": "app",
+			"server": {"http": {"port": 8080}, "tags": ["a"], "tls": null},
+			"x": 1
+		}`),
+		json.Unmarshal,
+	)
+	expect(t, source.Open(), nil)
+
+	testCases := []struct {
+		key      string
+		expected string
+		ok       bool
+		err      error
+	}{
+		{"db.host", "localhost", true, nil},
+		{"db.name", "app", true, nil},
+		{"server.http.port", "8080", true, nil},
+		{"x", "1", true, nil},
+		{"db.user", "", false, nil},
+		{"server.http.host", "", false, nil},
+		{"server.tls.cert", "", false, nil},
+		{"y.z", "", false, nil},
+		{"x.y", "", false, fmt.Errorf("invalid key x.y: x is not an object")},
+		{"server.tags.first", "", false, fmt.Errorf("invalid key server.tags.first: server.tags is not an object")},
+		{"db.host.name", "", false, fmt.Errorf("invalid key db.host.name: db.host is not an object")},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.key, func(t *testing.T) {
+			var v string
+			ok, err := source.Get(tt.key, NewValue(&v))
+			expect(t, err, tt.err)
+			expect(t, ok, tt.ok)
+			expect(t, v, tt.expected)
+		})
+	}
+
+	var fs FlagSet
+	port := fs.Int("port", 0, "", JSON("server.http.port"))
+	host := fs.String("host", "default", "", JSON("server.http.host"))
+	fsys := fstest.MapFS{"config.json": &fstest.MapFile{Data: []byte(`{"server": {"http": {"port": 9090}}}`)}}
+	source = FSVia(fsys, "config.json", json.Unmarshal)
+	expect(t, fs.Parse(nil, &jsonSource{source.(*FileSource)}), nil)
+	expect(t, *port, 9090)
+	expect(t, *host, "default")
+}
+
+func TestReaderSource(t *testing.T) {
+	source := ReaderSource(
+		strings.NewReader(`{"foo": "bar", "baz": [1, 2]}`),
+		json.Unmarshal,
+	)
+	if err := source.Open(); err != nil {
+		t.Fatalf("unable to open source: %s", err)
+	}
+
+	var s string
+	ok, err := source.Get("foo", NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, s, "bar")
+
+	var ints []int
+	ok, err = source.Get("baz", NewValue(&ints))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, ints, []int{1, 2})
+
+	ok, err = source.Get("qux", NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, false)
+
+	var fs FlagSet
+	foo := fs.String("foo", "", "", Config("foo"))
+	err = fs.Parse(nil, ReaderSource(strings.NewReader(`{"foo": "bar"}`), json.Unmarshal))
+	expect(t, err, nil)
+	expect(t, *foo, "bar")
+
+	invalid := ReaderSource(strings.NewReader(`{`), json.Unmarshal)
+	if err := invalid.Open(); err == nil {
+		t.Errorf("expecting error parsing invalid content, got nil instead")
+	}
+}
+
+func TestSourceMaxSize(t *testing.T) {
+	content := `{"foo": "bar"}`
+
+	testCases := []struct {
+		name     string
+		source   Source
+		expected error
+	}{
+		{
+			"reader within limit",
+			ReaderSourceWithLimit(strings.NewReader(content), json.Unmarshal, int64(len(content))),
+			nil,
+		},
+		{
+			"reader over limit",
+			ReaderSourceWithLimit(strings.NewReader(content), json.Unmarshal, int64(len(content)-1)),
+			ErrMaxSize,
+		},
+		{
+			"reader without limit",
+			ReaderSourceWithLimit(strings.NewReader(content), json.Unmarshal, 0),
+			nil,
+		},
+		{
+			"file over limit",
+			&FileSource{
+				File:    "config.json",
+				Parser:  json.Unmarshal,
+				FS:      fstest.MapFS{"config.json": &fstest.MapFile{Data: []byte(content)}},
+				MaxSize: 4,
+			},
+			ErrMaxSize,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, tt.source.Open(), tt.expected)
+		})
+	}
+}
+
+func TestWithMaxSize(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "flagga-*.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error creating file: %s", err)
+	}
+	defer os.Remove(f.Name())
+
+	content := "host: localhost\nport: 8080\n"
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+	f.Close()
+
+	source := WithMaxSize(YAMLVia(f.Name(), unmarshalTestYAML), int64(len(content)-1))
+	expect(t, source.Open(), ErrMaxSize)
+
+	source = WithMaxSize(YAMLVia(f.Name(), unmarshalTestYAML), int64(len(content)))
+	expect(t, source.Open(), nil)
+
+	var fs FlagSet
+	fs.String("host", "", "", YAML("host"))
+	err = fs.Parse(nil, WithMaxSize(Optional(YAMLVia(f.Name(), unmarshalTestYAML)), 4))
+	expect(t, err, ErrMaxSize)
+
+	source = WithMaxSize(JSONVia("config.json"), 4)
+	expect(t, source.(*jsonSource).MaxSize, int64(4))
+
+	defer func() {
+		expect(t, recover(), fmt.Errorf("source of type flagga.envSource doesn't read a file"))
+	}()
+	WithMaxSize(EnvPrefix(""), 4)
+}
+
+func TestFileVia(t *testing.T) {
+	s, err := FileVia("config.JSON")
+	expect(t, err, nil)
+	expect(t, KindOf(s), JSONKind)
+
+	_, err = FileVia("config.ini")
+	expect(t, err, fmt.Errorf("unsupported config file format: config.ini"))
+
+	RegisterFileFormat(".ini", func(file string) Source {
+		return NewFileSource(file, json.Unmarshal)
+	})
+	defer delete(fileFormats, ".ini")
+
+	s, err = FileVia("config.ini")
+	expect(t, err, nil)
+	expect(t, s.(*FileSource).File, "config.ini")
+}
+
+func TestGzipFile(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "flagga-gzip-*.json.gz")
+	if err != nil {
+		t.Fatalf("unexpected error creating file: %s", err)
+	}
+	defer os.Remove(f.Name())
+
+	w := gzip.NewWriter(f)
+	if _, err := w.Write([]byte(`{"foo": "bar"}`)); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+	w.Close()
+	f.Close()
+
+	source, err := FileVia(f.Name())
+	expect(t, err, nil)
+	expect(t, KindOf(source), JSONKind)
+	expect(t, source.Open(), nil)
+
+	var s string
+	ok, err := JSON("foo").Get([]Source{source}, NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, s, "bar")
+
+	source.(*jsonSource).MaxSize = 10
+	expect(t, source.Open(), ErrMaxSize)
+
+	var buf bytes.Buffer
+	w = gzip.NewWriter(&buf)
+	w.Write([]byte(`{"foo": "baz"}`))
+	w.Close()
+
+	source = ReaderSource(&buf, json.Unmarshal)
+	expect(t, source.Open(), nil)
+	ok, err = source.Get("foo", NewValue(&s))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, s, "baz")
+
+	source = ReaderSource(bytes.NewReader([]byte{0x1f, 0x8b, 0}), json.Unmarshal)
+	expect(t, source.Open(), io.ErrUnexpectedEOF)
+}
+
+func TestEnvFileVia(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "flagga-envfile")
+	if err != nil {
+		t.Fatalf("unexpected error creating file: %s", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`# database config
+; another comment
+DB_HOST=localhost
+  DB_PORT = 5432
+DB_USER="admin user"
+DB_PASS='p@ss "word"'
+DB_OPTS="a=\"b\"\tc"
+DB_LONG=foo \
+bar
+
+EMPTY=
+`)
+	if err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+	f.Close()
+
+	source := EnvFileVia(f.Name())
+	expect(t, KindOf(source), EnvKind)
+	expect(t, source.Open(), nil)
+
+	testCases := []struct {
+		key      string
+		ok       bool
+		expected string
+	}{
+		{"DB_HOST", true, "localhost"},
+		{"DB_PORT", true, "5432"},
+		{"DB_USER", true, "admin user"},
+		{"DB_PASS", true, `p@ss "word"`},
+		{"DB_OPTS", true, "a=\"b\"\tc"},
+		{"DB_LONG", true, "foo bar"},
+		{"EMPTY", true, ""},
+		{"MISSING", false, ""},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.key, func(t *testing.T) {
+			var s string
+			ok, err := source.Get(tt.key, NewValue(&s))
+			expect(t, err, nil)
+			expect(t, ok, tt.ok)
+			expect(t, s, tt.expected)
+		})
+	}
+}
+
+func TestEnvFileViaInvalid(t *testing.T) {
+	testCases := []string{
+		"export FOO=bar",
+		"FOO",
+		"=bar",
+		"1FOO=bar",
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt, func(t *testing.T) {
+			var values map[string]interface{}
+			err := parseEnvFile([]byte("A=b\n"+tt), &values)
+			expect(t, err, fmt.Errorf("invalid environment file line 2: %s", tt))
+		})
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "flagga-config-env")
+	if err != nil {
+		t.Fatalf("unexpected error creating dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	envFile := filepath.Join(dir, "env.json")
+	defaultFile := filepath.Join(dir, "default.json")
+	missingFile := filepath.Join(dir, "missing.json")
+	if err := ioutil.WriteFile(envFile, []byte(`{"port": 9090}`), 0644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+	if err := ioutil.WriteFile(defaultFile, []byte(`{"port": 8080}`), 0644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+
+	testCases := []struct {
+		name        string
+		env         string
+		defaultPath string
+		ok          bool
+		port        int
+	}{
+		{"env", envFile, defaultFile, true, 9090},
+		{"env without default", envFile, "", true, 9090},
+		{"default", "", defaultFile, true, 8080},
+		{"missing default", "", missingFile, false, 0},
+		{"no path", "", "", false, 0},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Unsetenv("FLAGGA_CONFIG")
+			if tt.env != "" {
+				os.Setenv("FLAGGA_CONFIG", tt.env)
+			}
+			defer os.Unsetenv("FLAGGA_CONFIG")
+
+			source, err := ConfigFromEnv("FLAGGA_CONFIG", tt.defaultPath)
+			expect(t, err, nil)
+			expect(t, source.Open(), nil)
+			defer source.Close()
+
+			var port int
+			ok, err := JSON("port").Get([]Source{source}, NewValue(&port))
+			expect(t, err, nil)
+			expect(t, ok, tt.ok)
+			expect(t, port, tt.port)
+		})
+	}
+
+	os.Setenv("FLAGGA_CONFIG", missingFile)
+	defer os.Unsetenv("FLAGGA_CONFIG")
+	source, err := ConfigFromEnv("FLAGGA_CONFIG", defaultFile)
+	expect(t, err, nil)
+	expect(t, source.Open() != nil, true)
+
+	_, err = ConfigFromEnv("FLAGGA_CONFIG", "")
+	expect(t, err, nil)
+
+	os.Setenv("FLAGGA_CONFIG", "config.ini")
+	_, err = ConfigFromEnv("FLAGGA_CONFIG", defaultFile)
+	expect(t, err, fmt.Errorf("unsupported config file format: config.ini"))
+}
+
+type slowSource struct {
+	delay  time.Duration
+	opened bool
+	value  map[string]interface{}
+}
+
+func (s *slowSource) Open() error {
+	return s.OpenContext(context.Background())
+}
+
+func (s *slowSource) OpenContext(ctx context.Context) error {
+	select {
+	case <-time.After(s.delay):
+		s.opened = true
+		s.value = map[string]interface{}{"port": 9090}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *slowSource) Close() error { return nil }
+
+func (s *slowSource) Get(key string, dst Value) (bool, error) {
+	return getValue(s.value, key, dst)
+}
+
+func (*slowSource) Kind() string { return "slow" }
+
+func TestSourceTimeout(t *testing.T) {
+	testCases := []struct {
+		name    string
+		timeout time.Duration
+		delay   time.Duration
+		err     error
+		port    int
+	}{
+		{"no timeout", 0, 10 * time.Millisecond, nil, 9090},
+		{"within timeout", time.Second, 10 * time.Millisecond, nil, 9090},
+		{"exceeds timeout", 10 * time.Millisecond, time.Second, ErrSourceTimeout, 0},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fs.SetSourceTimeout(tt.timeout)
+			port := fs.Int("port", 8080, "", KindExtractor("slow", "port"))
+
+			source := &slowSource{delay: tt.delay}
+			expect(t, fs.Parse(nil, EnvPrefix(""), source), tt.err)
+			expect(t, source.opened, tt.err == nil)
+			expect(t, *port, tt.port)
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var fs FlagSet
+	fs.Int("port", 8080, "", KindExtractor("slow", "port"))
+	expect(t, fs.ParseContext(ctx, nil, &slowSource{delay: time.Second}), context.Canceled)
+}
+
+func TestOptional(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "flagga-optional")
+	if err != nil {
+		t.Fatalf("unexpected error creating dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	validFile := filepath.Join(dir, "valid.json")
+	invalidFile := filepath.Join(dir, "invalid.json")
+	if err := ioutil.WriteFile(validFile, []byte(`{"port": 9090}`), 0644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+	if err := ioutil.WriteFile(invalidFile, []byte(`{"port": `), 0644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+
+	testCases := []struct {
+		name string
+		file string
+		err  bool
+		port int
+	}{
+		{"present", validFile, false, 9090},
+		{"missing", filepath.Join(dir, "missing.json"), false, 8080},
+		{"malformed", invalidFile, true, 0},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			port := fs.Int("port", 8080, "", JSON("port"), Config("port"))
+
+			err := fs.Parse(nil, Optional(JSONVia(tt.file)))
+			expect(t, err != nil, tt.err)
+			expect(t, *port, tt.port)
+		})
+	}
+
+	os.Setenv("OPTIONAL_port", "9090")
+	defer os.Unsetenv("OPTIONAL_port")
+
+	var port int
+	source := Optional(EnvPrefix("OPTIONAL_"))
+	expect(t, source.Open(), nil)
+	ok, err := Config("port").Get([]Source{source}, NewValue(&port))
+	expect(t, err, nil)
+	expect(t, ok, false)
+
+	ok, err = Env("port").Get([]Source{source}, NewValue(&port))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, port, 9090)
+}
+
+// unmarshalTestYAML decodes the lines "key: value" of a YAML like the YAML
+// libraries do, with maps with keys of any type and integers as ints. Keys
+// indented with two spaces belong to the map of the previous unindented key
+// and values in brackets are lists.
+func unmarshalTestYAML(data []byte, dst interface{}) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	var root = make(map[interface{}]interface{})
+	var parent map[interface{}]interface{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid line: %s", line)
+		}
+
+		var key interface{} = strings.TrimSpace(parts[0])
+		if n, err := strconv.Atoi(key.(string)); err == nil {
+			key = n
+		}
+
+		var value interface{}
+		raw := strings.TrimSpace(parts[1])
+		if strings.HasPrefix(raw, "[") {
+			var list []interface{}
+			for _, v := range strings.Split(strings.Trim(raw, "[]"), ",") {
+				list = append(list, yamlScalar(strings.TrimSpace(v)))
+			}
+			value = list
+		} else if raw != "" {
+			value = yamlScalar(raw)
+		}
+
+		if strings.HasPrefix(line, "  ") {
+			parent[key] = value
+		} else if value == nil {
+			parent = make(map[interface{}]interface{})
+			root[key] = parent
+		} else {
+			root[key] = value
+		}
+	}
+
+	*dst.(*interface{}) = root
+	return nil
+}
+
+func yamlScalar(s string) interface{} {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	return s
+}
+
+func TestYAMLVia(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "flagga-*.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error creating file: %s", err)
+	}
+	defer os.Remove(f.Name())
+
+	content := "host: localhost\nport: 8080\ntimeout: 30\ntags: [a, b]\nlimits:\n  cpu: 2\n  1: 3\n"
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+	f.Close()
+
+	source := YAMLVia(f.Name(), nil)
+	expect(t, source.Open(), fmt.Errorf("no YAML decoder, it must be given to YAMLVia or set with SetDecoder"))
+
+	var fs FlagSet
+	host := fs.String("host", "", "", YAML("host"))
+	port := fs.Int("port", 0, "", YAML("port"))
+	timeout := fs.Duration("timeout", 0, "", YAML("timeout"))
+	fs.Lookup("timeout").DurationSeconds = true
+	tags := fs.StringList("tags", nil, "", YAML("tags"))
+	limits := fs.IntMap("limits", nil, "", YAML("limits"))
+	other := fs.String("other", "", "", JSON("host"))
+
+	expect(t, fs.Parse(nil, YAMLVia(f.Name(), unmarshalTestYAML)), nil)
+	expect(t, *host, "localhost")
+	expect(t, *port, 8080)
+	expect(t, *timeout, 30*time.Second)
+	expect(t, *tags, []string{"a", "b"})
+	expect(t, *limits, map[string]int{"cpu": 2, "1": 3})
+	expect(t, *other, "")
+
+	var value map[string]interface{}
+
+	expect(t, decodeConfig("YAML", unmarshalTestYAML, nil, &value), nil)
+	expect(t, value, map[string]interface{}{})
+
+	unmarshalList := func(data []byte, dst interface{}) error {
+		*dst.(*interface{}) = []interface{}{1, 2}
+		return nil
+	}
+	expect(t, decodeConfig("YAML", unmarshalList, []byte("- 1\n- 2"), &value), fmt.Errorf("invalid YAML config file: expecting a map, got []interface {}"))
+}
+
+func TestYAMLFileVia(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "flagga-yaml")
+	if err != nil {
+		t.Fatalf("unexpected error creating dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, ext := range []string{".yaml", ".yml"} {
+		t.Run(ext, func(t *testing.T) {
+			file := filepath.Join(dir, "config"+ext)
+			if err := ioutil.WriteFile(file, []byte("host: localhost"), 0644); err != nil {
+				t.Fatalf("unexpected error writing file: %s", err)
+			}
+
+			source, err := FileVia(file)
+			expect(t, err, nil)
+			expect(t, KindOf(source), YAMLKind)
+
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fs.String("host", "", "", YAML("host"))
+			expect(t, fs.Parse(nil, source), fmt.Errorf("no YAML decoder, it must be given to YAMLVia or set with SetDecoder"))
+
+			os.Setenv("FLAGGA_YAML_CONFIG", file)
+			defer os.Unsetenv("FLAGGA_YAML_CONFIG")
+
+			fromEnv, err := ConfigFromEnv("FLAGGA_YAML_CONFIG", "")
+			expect(t, err, nil)
+
+			for _, source := range []Source{source, fromEnv} {
+				fs := NewFlagSet("", "", ContinueOnError)
+				fs.SetDecoder(YAMLKind, unmarshalTestYAML)
+				host := fs.String("host", "", "", YAML("host"))
+				expect(t, fs.Parse(nil, source), nil)
+				expect(t, *host, "localhost")
+			}
+		})
+	}
+}
+
+func TestTOMLVia(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "flagga-*.toml")
+	if err != nil {
+		t.Fatalf("unexpected error creating file: %s", err)
+	}
+	defer os.Remove(f.Name())
+
+	content := "port = 8080\nstart = 2024-03-01T10:30:00Z\n"
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+	f.Close()
+
+	source := TOMLVia(f.Name(), nil)
+	expect(t, source.Open(), fmt.Errorf("no TOML decoder, it must be given to TOMLVia or set with SetDecoder"))
+
+	start := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	// the values as decoded by the TOML libraries, with native times and
+	// durations, 64-bit integers and arrays of tables
+	unmarshal := func(data []byte, dst interface{}) error {
+		expect(t, string(data), content)
+		*dst.(*interface{}) = map[string]interface{}{
+			"port":    int64(8080),
+			"start":   start,
+			"timeout": 90 * time.Second,
+			"servers": []map[string]interface{}{{"host": "a"}, {"host": "b"}},
+		}
+		return nil
+	}
+
+	jsonFile, err := ioutil.TempFile(os.TempDir(), "flagga-*.json")
+	if err != nil {
+		t.Fatalf("unexpected error creating file: %s", err)
+	}
+	defer os.Remove(jsonFile.Name())
+
+	if _, err := jsonFile.WriteString(`{"port": 9090}`); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+	jsonFile.Close()
+
+	var fs FlagSet
+	port := fs.Int("port", 0, "", TOML("port"))
+	jsonPort := fs.Int("json-port", 0, "", JSON("port"))
+	startTime := fs.Time("start", time.Time{}, "", "2006-01-02", TOML("start"))
+	timeout := fs.Duration("timeout", 0, "", TOML("timeout"))
+
+	expect(t, fs.Parse(nil, JSONVia(jsonFile.Name()), TOMLVia(f.Name(), unmarshal)), nil)
+	expect(t, *port, 8080)
+	expect(t, *jsonPort, 9090)
+	expect(t, *startTime, start)
+	expect(t, *timeout, 90*time.Second)
+
+	var servers interface{}
+	_, err = fs.sources[1].Get("servers", valueFunc(func(v interface{}) error {
+		servers = v
+		return nil
+	}))
+	expect(t, err, nil)
+	expect(t, servers, []interface{}{
+		map[string]interface{}{"host": "a"},
+		map[string]interface{}{"host": "b"},
+	})
+}
+
+func TestTOMLDuration(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "flagga-toml")
+	if err != nil {
+		t.Fatalf("unexpected error creating dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.toml")
+	content := "nanos = 90000000000\ntext = \"1m30s\"\nlist = [\"1s\", \"2m\"]\n"
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+
+	source, err := FileVia(file)
+	expect(t, err, nil)
+	expect(t, KindOf(source), TOMLKind)
+
+	var fs FlagSet
+	// TOML has no durations, so they are decoded as integers or strings
+	fs.SetDecoder(TOMLKind, func(data []byte, dst interface{}) error {
+		expect(t, string(data), content)
+		*dst.(*interface{}) = map[string]interface{}{
+			"nanos": int64(90000000000),
+			"text":  "1m30s",
+			"list":  []interface{}{"1s", "2m"},
+		}
+		return nil
+	})
+	nanos := fs.Duration("nanos", 0, "", TOML("nanos"))
+	text := fs.Duration("text", 0, "", TOML("text"))
+	list := fs.DurationList("list", nil, "", TOML("list"))
+
+	expect(t, fs.Parse(nil, source), nil)
+	expect(t, *nanos, 90*time.Second)
+	expect(t, *text, 90*time.Second)
+	expect(t, *list, []time.Duration{time.Second, 2 * time.Minute})
+}
+
+type valueFunc func(interface{}) error
+
+func (f valueFunc) Set(v interface{}) error { return f(v) }
+
+func TestYAMLWithEnv(t *testing.T) {
+	f, err := ioutil.TempFile(os.TempDir(), "flagga-*.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error creating file: %s", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("host: localhost\nport: 8080\ndb_host: db.local")
+	if err != nil {
+		t.Fatalf("unexpected error writing file: %s", err)
+	}
+	f.Close()
+
+	source := YAMLWithEnv(f.Name(), "YAMLENV_", nil)
+	expect(t, source.Open(), fmt.Errorf("no YAML decoder, it must be given to YAMLVia or set with SetDecoder"))
+
+	os.Setenv("YAMLENV_port", "9090")
+	defer os.Unsetenv("YAMLENV_port")
+
+	source = YAMLWithEnv(f.Name(), "YAMLENV_", unmarshalTestYAML)
+	expect(t, source.Open(), nil)
+	defer source.Close()
+
+	// the environment is read when the source is opened
+	os.Setenv("YAMLENV_host", "example.com")
+	defer os.Unsetenv("YAMLENV_host")
+
+	var port, host string
+	ok, err := Config("port").Get([]Source{source}, NewValue(&port))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, port, "9090")
+
+	ok, err = YAML("host").Get([]Source{source}, NewValue(&host))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, host, "localhost")
+
+	ok, err = source.Get("missing", NewValue(&host))
+	expect(t, err, nil)
+	expect(t, ok, false)
+
+	var fs FlagSet
+	fs.SetDecoder(YAMLKind, unmarshalTestYAML)
+	portFlag := fs.Int("port", 0, "", YAML("port"))
+	expect(t, fs.Parse(nil, YAMLWithEnv(f.Name(), "YAMLENV_", nil)), nil)
+	expect(t, *portFlag, 9090)
+
+	// the variables match the keys of the file case-insensitively
+	os.Setenv("YAMLENV_DB_HOST", "db.example.com")
+	defer os.Unsetenv("YAMLENV_DB_HOST")
+	os.Setenv("YAMLENV_DB_USER", "admin")
+	defer os.Unsetenv("YAMLENV_DB_USER")
+
+	fs = FlagSet{}
+	dbHost := fs.String("db-host", "", "", YAML("db_host"))
+	dbUser := fs.String("db-user", "", "", YAML("DB_USER"))
+	expect(t, fs.Parse(nil, YAMLWithEnv(f.Name(), "YAMLENV_", unmarshalTestYAML)), nil)
+	expect(t, *dbHost, "db.example.com")
+	expect(t, *dbUser, "admin")
+}
+
+func TestConfigTreeVia(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "flagga-tree")
+	if err != nil {
+		t.Fatalf("unexpected error creating dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"port":             "8080\n",
+		"db/host":          "localhost",
+		"db/auth/user":     "admin\n",
+		".hidden":          "hidden",
+		"..data/port":      "9090",
+		"db/.secret/token": "token",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("unexpected error creating dir: %s", err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error writing file: %s", err)
+		}
+	}
+
+	source := ConfigTreeVia(dir)
+	expect(t, source.Open(), nil)
+	expect(t, source.(*treeSource).value, map[string]interface{}{
+		"port":         "8080",
+		"db.host":      "localhost",
+		"db.auth.user": "admin",
+	})
+
+	var port int
+	ok, err := Config("port").Get([]Source{source}, NewValue(&port))
+	expect(t, err, nil)
+	expect(t, ok, true)
+	expect(t, port, 8080)
+
+	expect(t, ConfigTreeVia(filepath.Join(dir, "missing")).Open() != nil, true)
+}
@@ -0,0 +1,74 @@
+package flagga
+
+import (
+	stdflag "flag"
+	"reflect"
+)
+
+// stdValue is a Value that forwards the values to the Value of a flag of the
+// standard library.
+type stdValue struct {
+	value stdflag.Value
+	// def is the value of the flag when it was defined, which is its default
+	// value.
+	def interface{}
+}
+
+func (v stdValue) Set(val interface{}) error {
+	var s string
+	assignString(&s, val)
+	return v.value.Set(s)
+}
+
+func (v stdValue) Get() interface{} {
+	if g, ok := v.value.(stdflag.Getter); ok {
+		return g.Get()
+	}
+	return v.value.String()
+}
+
+func (v stdValue) String() string { return v.value.String() }
+
+func (v stdValue) isBoolFlag() bool {
+	b, ok := v.value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// holdsDefault reports whether the value of the given flag already holds its
+// default value, as values of the standard library do when they are defined,
+// so setting it again is not needed. Setting it again would repeat the
+// default of values appending to a list.
+func holdsDefault(f *Flag) bool {
+	v, ok := f.Value.(stdValue)
+	return ok && reflect.DeepEqual(f.Default, v.def)
+}
+
+// ImportStdlib defines in the flag set all the flags defined in the given flag
+// set of the standard library, with the same names, default values and
+// usages, so programs can be migrated incrementally. The values of the flags
+// are set using the Value of the flags of the standard library, so they can
+// be read from the same variables. The default values are the ones returned
+// by the Get method of the values implementing flag.Getter, such as the ones
+// of flag.Int, or the DefValue of the flags otherwise.
+func (fs *FlagSet) ImportStdlib(std *stdflag.FlagSet) {
+	std.VisitAll(func(f *stdflag.Flag) {
+		var def interface{} = f.DefValue
+		if g, ok := f.Value.(stdflag.Getter); ok {
+			def = snapshot(g.Get())
+		}
+		fs.addFlag(f.Name, def, f.Usage, stdValue{f.Value, def}, nil)
+	})
+}
+
+// FlagValueVar adds a new flag for a Value of the flag package of the
+// standard library. When the flag set is parsed it will call the Set method
+// of the given value with the values formatted as strings. The default value
+// of the flag is the String of the value when the flag was defined.
+func (fs *FlagSet) FlagValueVar(
+	v stdflag.Value,
+	name string,
+	usage string,
+	extractors ...Extractor,
+) {
+	fs.addFlag(name, v.String(), usage, stdValue{v, v.String()}, extractors)
+}
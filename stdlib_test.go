@@ -0,0 +1,79 @@
+package flagga
+
+import (
+	"bytes"
+	stdflag "flag"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportStdlib(t *testing.T) {
+	std := stdflag.NewFlagSet("std", stdflag.ContinueOnError)
+	name := std.String("name", "foo", "the name")
+	verbose := std.Bool("v", false, "verbose")
+	timeout := std.Duration("timeout", time.Second, "the timeout")
+	retries := std.Int("retries", 3, "the retries")
+
+	var fs FlagSet
+	fs.ImportStdlib(std)
+	other := fs.String("other", "", "")
+
+	err := fs.Parse([]string{"-name", "bar", "-v", "--timeout=2m", "-other", "baz"})
+	expect(t, err, nil)
+	expect(t, *name, "bar")
+	expect(t, *verbose, true)
+	expect(t, *timeout, 2*time.Minute)
+	expect(t, *retries, 3)
+	expect(t, *other, "baz")
+
+	f := fs.Lookup("timeout")
+	expect(t, f.Usage, "the timeout")
+	expect(t, f.Default, time.Second)
+	expect(t, f.Value.(Getter).Get(), 2*time.Minute)
+	expect(t, fs.Lookup("retries").Default, 3)
+
+	var buf bytes.Buffer
+	fs = FlagSet{}
+	fs.ImportStdlib(std)
+	fs.SetOutput(&buf)
+	fs.PrintDefaults()
+	expect(t, strings.Contains(buf.String(), "  -retries int\n  \tthe retries (default value: 3)\n"), true)
+}
+
+// csvValue is a stdlib-style Value of comma-separated values.
+type csvValue []string
+
+func (v *csvValue) String() string { return strings.Join(*v, ",") }
+
+func (v *csvValue) Set(s string) error {
+	*v = append(*v, strings.Split(s, ",")...)
+	return nil
+}
+
+func TestFlagValueVar(t *testing.T) {
+	os.Setenv("TEST_FLAG_VALUE", "x,y")
+	defer os.Unsetenv("TEST_FLAG_VALUE")
+
+	var fs FlagSet
+	var arg, env, def csvValue
+	def = csvValue{"d"}
+	fs.FlagValueVar(&arg, "arg", "")
+	fs.FlagValueVar(&env, "env", "", Env("TEST_FLAG_VALUE"))
+	fs.FlagValueVar(&def, "def", "default values")
+
+	err := fs.Parse([]string{"-arg", "a,b", "-arg=c"}, EnvPrefix(""))
+	expect(t, err, nil)
+	expect(t, arg, csvValue{"a", "b", "c"})
+	expect(t, env, csvValue{"x", "y"})
+	expect(t, def, csvValue{"d"})
+	expect(t, fs.Lookup("def").Default, "d")
+
+	fs = FlagSet{}
+	def = csvValue{"d"}
+	fs.FlagValueVar(&def, "def", "")
+	expect(t, fs.ApplyDefaults(map[string]interface{}{"def": "e"}), nil)
+	expect(t, fs.Parse(nil), nil)
+	expect(t, def, csvValue{"d", "e"})
+}
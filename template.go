@@ -0,0 +1,96 @@
+package flagga
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// StringTemplate adds a new string flag whose default value is a
+// text/template rendered once all the flags are filled, and returns a pointer
+// to the value that will be filled once the flag set is parsed. The
+// environment variables are the data of the template, so {{.HOME}}/.app is
+// rendered with the value of $HOME, and the env and flag functions return the
+// value of an environment variable or of another flag by name. Errors in the
+// template are returned by Parse.
+func (fs *FlagSet) StringTemplate(
+	name, tmpl, usage string,
+	extractors ...Extractor,
+) *string {
+	v := new(string)
+	fs.StringTemplateVar(v, name, tmpl, usage, extractors...)
+	return v
+}
+
+// StringTemplateVar adds a new string flag whose default value is a
+// text/template, as StringTemplate does. When the flag set is parsed it will
+// fill the given pointer.
+func (fs *FlagSet) StringTemplateVar(
+	v *string,
+	name string,
+	tmpl string,
+	usage string,
+	extractors ...Extractor,
+) {
+	fs.StringVar(v, name, tmpl, usage, extractors...)
+	if fs.templates == nil {
+		fs.templates = make(map[string]string)
+	}
+	fs.templates[fs.normalizeName(name)] = tmpl
+}
+
+// renderTemplates replaces the default values of the given template flags by
+// their rendered templates, in the order the flags were defined.
+func (fs *FlagSet) renderTemplates(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return fs.flagIndex(names[i]) < fs.flagIndex(names[j])
+	})
+
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if idx := strings.IndexRune(kv, '='); idx > 0 {
+			env[kv[:idx]] = kv[idx+1:]
+		}
+	}
+
+	funcs := template.FuncMap{
+		"env": os.Getenv,
+		"flag": func(name string) (string, error) {
+			f := fs.Lookup(name)
+			if f == nil {
+				return "", fmt.Errorf(fs.msgs().UndefinedFlag, name)
+			}
+
+			if g, ok := f.Value.(Getter); ok {
+				return fmt.Sprint(g.Get()), nil
+			}
+			return fmt.Sprint(f.Value), nil
+		},
+	}
+
+	for _, name := range names {
+		t, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(fs.templates[name])
+		if err != nil {
+			return fmt.Errorf(fs.msgs().InvalidTemplate, name, err)
+		}
+
+		var buf strings.Builder
+		if err := t.Execute(&buf, env); err != nil {
+			return fmt.Errorf(fs.msgs().InvalidTemplate, name, err)
+		}
+
+		f := fs.flags[name]
+		if err := fs.setFlag(f, buf.String()); err != nil {
+			return err
+		}
+		fs.audit(f, OriginDefault)
+	}
+
+	return nil
+}
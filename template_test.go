@@ -0,0 +1,81 @@
+package flagga
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStringTemplate(t *testing.T) {
+	os.Setenv("FLAGGA_TMPL_HOME", "/home/jane")
+	defer os.Unsetenv("FLAGGA_TMPL_HOME")
+
+	var fs FlagSet
+	name := fs.String("name", "app", "")
+	dir := fs.StringTemplate("dir", "{{.FLAGGA_TMPL_HOME}}/.{{flag \"name\"}}", "")
+	cache := fs.StringTemplate("cache", `{{env "FLAGGA_TMPL_HOME"}}/.cache`, "")
+
+	expect(t, fs.Parse([]string{"-name", "tool", "-cache", "/tmp"}), nil)
+	expect(t, *name, "tool")
+	expect(t, *dir, "/home/jane/.tool")
+	expect(t, *cache, "/tmp")
+	expect(t, fs.Lookup("dir").Default, "{{.FLAGGA_TMPL_HOME}}/.{{flag \"name\"}}")
+
+	var dirs []interface{}
+	for _, e := range fs.AuditLog() {
+		if e.Flag == "dir" {
+			dirs = append(dirs, e.Value)
+		}
+	}
+	expect(t, dirs, []interface{}{"{{.FLAGGA_TMPL_HOME}}/.{{flag \"name\"}}", "/home/jane/.tool"})
+}
+
+func TestStringTemplateUsage(t *testing.T) {
+	fs := NewFlagSet("", "", ContinueOnError)
+	fs.StringTemplate("dir", "{{.HOME}}/.app", "data directory")
+
+	var buf bytes.Buffer
+	fs.SetOutput(&buf)
+	fs.PrintDefaults()
+	expect(t, buf.String(), "  -dir string\n  \tdata directory\n")
+}
+
+func TestStringTemplateError(t *testing.T) {
+	testCases := []struct {
+		tmpl string
+		err  error
+	}{
+		{
+			"{{.FLAGGA_TMPL_UNDEFINED}}",
+			fmt.Errorf(`invalid template for flag dir: template: dir:1:2: executing "dir" at <.FLAGGA_TMPL_UNDEFINED>: map has no entry for key "FLAGGA_TMPL_UNDEFINED"`),
+		},
+		{
+			"{{.HOME",
+			fmt.Errorf(`invalid template for flag dir: template: dir:1: unclosed action`),
+		},
+		{
+			`{{flag "undefined"}}`,
+			fmt.Errorf(`invalid template for flag dir: template: dir:1:2: executing "dir" at <flag "undefined">: error calling flag: undefined flag: undefined`),
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			var buf bytes.Buffer
+			fs := NewFlagSet("", "", ContinueOnError)
+			fs.SetOutput(&buf)
+			fs.StringTemplate("dir", tt.tmpl, "")
+			expect(t, fs.Parse(nil), tt.err)
+			expect(t, strings.HasPrefix(buf.String(), tt.err.Error()), true)
+		})
+	}
+
+	fs := NewFlagSet("", "", ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.SetMessages(Messages{InvalidTemplate: "bad template in %s: %s", UndefinedFlag: "no flag %s"})
+	fs.StringTemplate("dir", `{{flag "undefined"}}`, "")
+	expect(t, fs.Parse(nil), fmt.Errorf(`bad template in dir: template: dir:1:2: executing "dir" at <flag "undefined">: error calling flag: no flag undefined`))
+}
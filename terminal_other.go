@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !windows
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly,!windows
+
+package flagga
+
+// disableEcho does nothing on the platforms where the echo of the terminal
+// can't be disabled, so secrets are echoed.
+func disableEcho() (func(), error) {
+	return func() {}, nil
+}
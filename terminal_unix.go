@@ -0,0 +1,42 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package flagga
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// disableEcho disables the echo of the terminal attached to the standard
+// input and returns a function to enable it again.
+func disableEcho() (func(), error) {
+	fd := os.Stdin.Fd()
+	var old syscall.Termios
+	if err := ioctlTermios(fd, ioctlGetTermios, &old); err != nil {
+		return nil, err
+	}
+
+	termios := old
+	termios.Lflag &^= syscall.ECHO
+	termios.Lflag |= syscall.ICANON | syscall.ISIG
+	if err := ioctlTermios(fd, ioctlSetTermios, &termios); err != nil {
+		return nil, err
+	}
+
+	return func() { _ = ioctlTermios(fd, ioctlSetTermios, &old) }, nil
+}
+
+func ioctlTermios(fd uintptr, req uintptr, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL,
+		fd,
+		req,
+		uintptr(unsafe.Pointer(termios)),
+	)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
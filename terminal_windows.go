@@ -0,0 +1,37 @@
+//go:build windows
+// +build windows
+
+package flagga
+
+import (
+	"os"
+	"syscall"
+)
+
+const enableEchoInput = 0x4
+
+var procSetConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// disableEcho disables the echo of the console attached to the standard
+// input and returns a function to enable it again.
+func disableEcho() (func(), error) {
+	h := syscall.Handle(os.Stdin.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(h, &mode); err != nil {
+		return nil, err
+	}
+
+	if err := setConsoleMode(h, mode&^enableEchoInput); err != nil {
+		return nil, err
+	}
+
+	return func() { _ = setConsoleMode(h, mode) }, nil
+}
+
+func setConsoleMode(h syscall.Handle, mode uint32) error {
+	r, _, err := procSetConsoleMode.Call(uintptr(h), uintptr(mode))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
@@ -1,8 +1,14 @@
 package flagga
 
 import (
+	"encoding"
 	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,6 +19,16 @@ type Value interface {
 	Set(val interface{}) error
 }
 
+// Getter is a Value whose current value can be retrieved. All the values
+// returned by NewValue are Getters.
+type Getter interface {
+	Value
+	// Get returns the current value.
+	Get() interface{}
+	// String returns the current value formatted as a string.
+	String() string
+}
+
 type value struct {
 	value interface{}
 }
@@ -41,6 +57,10 @@ func (v *value) Set(val interface{}) error {
 		return assignInt64(v, val)
 	case *time.Duration:
 		return assignDuration(v, val)
+	case *TimeOfDay:
+		return assignTimeOfDay(v, val)
+	case *time.Time:
+		return assignTime(v, val, time.RFC3339)
 	case *[]string:
 		assignStringList(v, val)
 		return nil
@@ -56,11 +76,31 @@ func (v *value) Set(val interface{}) error {
 		return assignUint64List(v, val)
 	case *[]time.Duration:
 		return assignDurationList(v, val)
+	case *net.IP:
+		return assignIP(v, val)
+	case *[]net.IP:
+		return assignIPList(v, val)
+	case *url.URL:
+		return assignURL(v, val)
+	case *[]url.URL:
+		return assignURLList(v, val)
+	case *map[string]int:
+		return assignIntMap(v, val)
+	case *map[string]bool:
+		return assignBoolMap(v, val)
 	}
 
 	panic(fmt.Errorf("invalid value of type: %T", v.value))
 }
 
+func (v *value) Get() interface{} {
+	return reflect.ValueOf(v.value).Elem().Interface()
+}
+
+func (v *value) String() string {
+	return prettyValue(v.Get())
+}
+
 func assignString(dst *string, val interface{}) {
 	switch val := val.(type) {
 	case string:
@@ -114,7 +154,7 @@ func assignInt(dst *int, val interface{}) error {
 	case float64:
 		*dst = int(val)
 	case string:
-		n, err := strconv.ParseInt(val, 10, 64)
+		n, err := strconv.ParseInt(stripDigitSeparators(val, '_'), 10, 64)
 		if err != nil {
 			return err
 		}
@@ -128,6 +168,27 @@ func assignInt(dst *int, val interface{}) error {
 	return nil
 }
 
+// stripDigitSeparators removes the given separator from a number when it's
+// used to group its digits, such as in 1_000_000. Separators that are not
+// between two digits are kept, so the number fails to parse.
+func stripDigitSeparators(s string, sep byte) string {
+	if strings.IndexByte(s, sep) < 0 {
+		return s
+	}
+
+	var buf = make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == sep && i > 0 && i < len(s)-1 && isDigit(s[i-1]) && isDigit(s[i+1]) {
+			continue
+		}
+		buf = append(buf, s[i])
+	}
+
+	return string(buf)
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
 func assignUint(dst *uint, val interface{}) error {
 	switch val := val.(type) {
 	case uint:
@@ -141,7 +202,7 @@ func assignUint(dst *uint, val interface{}) error {
 	case float64:
 		*dst = uint(val)
 	case string:
-		n, err := strconv.ParseUint(val, 10, 64)
+		n, err := strconv.ParseUint(stripDigitSeparators(val, '_'), 10, 64)
 		if err != nil {
 			return err
 		}
@@ -168,7 +229,7 @@ func assignInt64(dst *int64, val interface{}) error {
 	case float64:
 		*dst = int64(val)
 	case string:
-		n, err := strconv.ParseInt(val, 10, 64)
+		n, err := strconv.ParseInt(stripDigitSeparators(val, '_'), 10, 64)
 		if err != nil {
 			return err
 		}
@@ -195,7 +256,7 @@ func assignUint64(dst *uint64, val interface{}) error {
 	case float64:
 		*dst = uint64(val)
 	case string:
-		n, err := strconv.ParseUint(val, 10, 64)
+		n, err := strconv.ParseUint(stripDigitSeparators(val, '_'), 10, 64)
 		if err != nil {
 			return err
 		}
@@ -257,6 +318,247 @@ func assignDuration(dst *time.Duration, val interface{}) error {
 	return nil
 }
 
+// rateValue is a Value for rates of events per second.
+type rateValue struct {
+	rate *float64
+}
+
+func (v rateValue) Set(val interface{}) error { return assignRate(v.rate, val) }
+func (v rateValue) Get() interface{}          { return *v.rate }
+func (v rateValue) String() string            { return prettyValue(*v.rate) }
+
+// assignRate assigns a rate in events per second. Strings can have the form
+// N/s, N/m or N/h to express the rate per second, minute or hour. Bare
+// numbers are events per second.
+func assignRate(dst *float64, val interface{}) error {
+	switch val := val.(type) {
+	case string:
+		idx := strings.IndexRune(val, '/')
+		if idx < 0 {
+			return assignFloat64(dst, val)
+		}
+
+		var n float64
+		if err := assignFloat64(&n, strings.TrimSpace(val[:idx])); err != nil {
+			return err
+		}
+
+		switch strings.TrimSpace(val[idx+1:]) {
+		case "s":
+			*dst = n
+		case "m":
+			*dst = n / 60
+		case "h":
+			*dst = n / 3600
+		default:
+			return fmt.Errorf("invalid rate unit in %q, expecting s, m or h", val)
+		}
+	case []byte:
+		return assignRate(dst, string(val))
+	default:
+		return assignFloat64(dst, val)
+	}
+
+	return nil
+}
+
+// aliasValue is the Value of an alias flag, which appends a preset list of
+// values to a list flag every time it's set to true.
+type aliasValue struct {
+	fs     *FlagSet
+	target string
+	values []string
+}
+
+func (v aliasValue) Set(val interface{}) error {
+	var enabled bool
+	if err := assignBool(&enabled, val); err != nil || !enabled {
+		return err
+	}
+
+	f, ok := v.fs.argFlag(v.target)
+	if !ok {
+		return fmt.Errorf(v.fs.msgs().UnknownFlag, v.target)
+	}
+
+	for _, s := range v.values {
+		if err := v.fs.setValue(f, s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// textValue is a Value for the types implementing encoding.TextUnmarshaler.
+type textValue struct {
+	value encoding.TextUnmarshaler
+}
+
+func (v textValue) Set(val interface{}) error {
+	if rv := reflect.ValueOf(v.value).Elem(); reflect.TypeOf(val) == rv.Type() {
+		rv.Set(reflect.ValueOf(val))
+		return nil
+	}
+
+	switch val := val.(type) {
+	case string:
+		return v.value.UnmarshalText([]byte(val))
+	case []byte:
+		return v.value.UnmarshalText(val)
+	case encoding.TextMarshaler:
+		text, err := val.MarshalText()
+		if err != nil {
+			return err
+		}
+		return v.value.UnmarshalText(text)
+	default:
+		return fmt.Errorf("cannot assign type %T to %T", val, v.value)
+	}
+}
+
+func (v textValue) Get() interface{} {
+	return reflect.ValueOf(v.value).Elem().Interface()
+}
+
+func (v textValue) String() string {
+	if m, ok := v.value.(encoding.TextMarshaler); ok {
+		if text, err := m.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(v.Get())
+}
+
+// TimeOfDay is a time of the day with a precision of seconds.
+type TimeOfDay struct {
+	Hour   int
+	Minute int
+	Second int
+}
+
+// Duration returns the time elapsed since midnight.
+func (t TimeOfDay) Duration() time.Duration {
+	return time.Duration(t.Hour)*time.Hour +
+		time.Duration(t.Minute)*time.Minute +
+		time.Duration(t.Second)*time.Second
+}
+
+// String returns the time of day in the HH:MM:SS format.
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d:%02d", t.Hour, t.Minute, t.Second)
+}
+
+// assignTimeOfDay assigns a time of day. Strings must have the form HH:MM or
+// HH:MM:SS using a 24-hour clock.
+func assignTimeOfDay(dst *TimeOfDay, val interface{}) error {
+	switch val := val.(type) {
+	case TimeOfDay:
+		*dst = val
+	case string:
+		parts := strings.Split(val, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return fmt.Errorf("invalid time of day %q, expecting HH:MM or HH:MM:SS", val)
+		}
+
+		var nums [3]int
+		for i, p := range parts {
+			n, err := strconv.Atoi(p)
+			if err != nil || len(p) != 2 {
+				return fmt.Errorf("invalid time of day %q, expecting HH:MM or HH:MM:SS", val)
+			}
+			nums[i] = n
+		}
+
+		if nums[0] > 23 || nums[1] > 59 || nums[2] > 59 {
+			return fmt.Errorf("time of day %q is out of range", val)
+		}
+
+		*dst = TimeOfDay{nums[0], nums[1], nums[2]}
+	case []byte:
+		return assignTimeOfDay(dst, string(val))
+	default:
+		return fmt.Errorf("cannot assign type %T to TimeOfDay", val)
+	}
+
+	return nil
+}
+
+// timeValue is a Value for times given in a custom layout.
+type timeValue struct {
+	t      *time.Time
+	layout string
+}
+
+func (v timeValue) Set(val interface{}) error { return assignTime(v.t, val, v.layout) }
+func (v timeValue) Get() interface{}          { return *v.t }
+func (v timeValue) String() string            { return prettyValue(*v.t) }
+
+// assignTime assigns a time. Strings are parsed using the given layout and
+// numbers are seconds since the Unix epoch.
+func assignTime(dst *time.Time, val interface{}, layout string) error {
+	switch val := val.(type) {
+	case time.Time:
+		*dst = val
+	case string:
+		t, err := time.Parse(layout, val)
+		if err != nil {
+			return err
+		}
+		*dst = t
+	case []byte:
+		return assignTime(dst, string(val), layout)
+	case int:
+		*dst = time.Unix(int64(val), 0)
+	case int64:
+		*dst = time.Unix(val, 0)
+	case float64:
+		sec, frac := math.Modf(val)
+		*dst = time.Unix(int64(sec), int64(frac*float64(time.Second)))
+	default:
+		return fmt.Errorf("cannot assign type %T to time.Time", val)
+	}
+
+	return nil
+}
+
+// enumValue is a Value for strings that must be one of a list of choices.
+type enumValue struct {
+	s          *string
+	choices    []string
+	ignoreCase bool
+}
+
+func (v enumValue) Set(val interface{}) error {
+	return assignEnum(v.s, val, v.choices, v.ignoreCase)
+}
+func (v enumValue) Get() interface{} { return *v.s }
+func (v enumValue) String() string   { return prettyValue(*v.s) }
+
+// assignEnum assigns one of the given choices. If ignoreCase is set, values
+// match the choices no matter their case, and the choice is assigned as it
+// is written in the choices.
+func assignEnum(dst *string, val interface{}, choices []string, ignoreCase bool) error {
+	var s string
+	switch val := val.(type) {
+	case string:
+		s = val
+	case []byte:
+		s = string(val)
+	default:
+		return fmt.Errorf("cannot assign type %T to enum", val)
+	}
+
+	for _, c := range choices {
+		if c == s || ignoreCase && strings.EqualFold(c, s) {
+			*dst = c
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid value %q, expecting one of: %s", s, strings.Join(choices, ", "))
+}
+
 func assignStringList(dst *[]string, val interface{}) {
 	switch val := val.(type) {
 	case []interface{}:
@@ -277,18 +579,14 @@ func assignIntList(dst *[]int, val interface{}) error {
 	switch val := val.(type) {
 	case []interface{}:
 		*dst = make([]int, len(val))
-		for i, v := range val {
-			if err := assignInt(&(*dst)[i], v); err != nil {
-				return err
-			}
-		}
+		return assignElements(len(val), func(i int) error {
+			return assignInt(&(*dst)[i], val[i])
+		})
 	case []string:
 		*dst = make([]int, len(val))
-		for i, v := range val {
-			if err := assignInt(&(*dst)[i], v); err != nil {
-				return err
-			}
-		}
+		return assignElements(len(val), func(i int) error {
+			return assignInt(&(*dst)[i], val[i])
+		})
 	case []int:
 		*dst = val
 	case []uint:
@@ -328,18 +626,14 @@ func assignUintList(dst *[]uint, val interface{}) error {
 	switch val := val.(type) {
 	case []interface{}:
 		*dst = make([]uint, len(val))
-		for i, v := range val {
-			if err := assignUint(&(*dst)[i], v); err != nil {
-				return err
-			}
-		}
+		return assignElements(len(val), func(i int) error {
+			return assignUint(&(*dst)[i], val[i])
+		})
 	case []string:
 		*dst = make([]uint, len(val))
-		for i, v := range val {
-			if err := assignUint(&(*dst)[i], v); err != nil {
-				return err
-			}
-		}
+		return assignElements(len(val), func(i int) error {
+			return assignUint(&(*dst)[i], val[i])
+		})
 	case []uint:
 		*dst = val
 	case []int:
@@ -379,18 +673,14 @@ func assignInt64List(dst *[]int64, val interface{}) error {
 	switch val := val.(type) {
 	case []interface{}:
 		*dst = make([]int64, len(val))
-		for i, v := range val {
-			if err := assignInt64(&(*dst)[i], v); err != nil {
-				return err
-			}
-		}
+		return assignElements(len(val), func(i int) error {
+			return assignInt64(&(*dst)[i], val[i])
+		})
 	case []string:
 		*dst = make([]int64, len(val))
-		for i, v := range val {
-			if err := assignInt64(&(*dst)[i], v); err != nil {
-				return err
-			}
-		}
+		return assignElements(len(val), func(i int) error {
+			return assignInt64(&(*dst)[i], val[i])
+		})
 	case []int64:
 		*dst = val
 	case []uint:
@@ -430,18 +720,14 @@ func assignUint64List(dst *[]uint64, val interface{}) error {
 	switch val := val.(type) {
 	case []interface{}:
 		*dst = make([]uint64, len(val))
-		for i, v := range val {
-			if err := assignUint64(&(*dst)[i], v); err != nil {
-				return err
-			}
-		}
+		return assignElements(len(val), func(i int) error {
+			return assignUint64(&(*dst)[i], val[i])
+		})
 	case []string:
 		*dst = make([]uint64, len(val))
-		for i, v := range val {
-			if err := assignUint64(&(*dst)[i], v); err != nil {
-				return err
-			}
-		}
+		return assignElements(len(val), func(i int) error {
+			return assignUint64(&(*dst)[i], val[i])
+		})
 	case []uint64:
 		*dst = val
 	case []int:
@@ -477,22 +763,288 @@ func assignUint64List(dst *[]uint64, val interface{}) error {
 	return nil
 }
 
-func assignDurationList(dst *[]time.Duration, val interface{}) error {
+func assignIP(dst *net.IP, val interface{}) error {
+	switch val := val.(type) {
+	case net.IP:
+		*dst = val
+	case string:
+		ip := net.ParseIP(strings.TrimSpace(val))
+		if ip == nil {
+			return fmt.Errorf("invalid IP address %q", val)
+		}
+		*dst = ip
+	case []byte:
+		return assignIP(dst, string(val))
+	default:
+		return fmt.Errorf("cannot assign type %T to net.IP", val)
+	}
+
+	return nil
+}
+
+// assignIPList assigns a list of IP addresses. Single strings can contain
+// several addresses separated by commas, which are appended to the list.
+func assignIPList(dst *[]net.IP, val interface{}) error {
 	switch val := val.(type) {
 	case []interface{}:
-		*dst = make([]time.Duration, len(val))
-		for i, v := range val {
-			if err := assignDuration(&(*dst)[i], v); err != nil {
+		*dst = make([]net.IP, len(val))
+		return assignElements(len(val), func(i int) error {
+			return assignIP(&(*dst)[i], val[i])
+		})
+	case []string:
+		*dst = make([]net.IP, len(val))
+		return assignElements(len(val), func(i int) error {
+			return assignIP(&(*dst)[i], val[i])
+		})
+	case []net.IP:
+		*dst = val
+	case string:
+		parts := strings.Split(val, ",")
+		var ips = make([]net.IP, len(parts))
+		if err := assignElements(len(parts), func(i int) error {
+			return assignIP(&ips[i], parts[i])
+		}); err != nil {
+			return err
+		}
+		*dst = append(*dst, ips...)
+	case []byte:
+		return assignIPList(dst, string(val))
+	case net.IP:
+		*dst = append(*dst, val)
+	default:
+		return fmt.Errorf("cannot assign type %T to []net.IP", val)
+	}
+
+	return nil
+}
+
+func assignURL(dst *url.URL, val interface{}) error {
+	switch val := val.(type) {
+	case url.URL:
+		*dst = val
+	case *url.URL:
+		*dst = *val
+	case string:
+		u, err := url.Parse(strings.TrimSpace(val))
+		if err != nil {
+			return err
+		}
+		*dst = *u
+	case []byte:
+		return assignURL(dst, string(val))
+	default:
+		return fmt.Errorf("cannot assign type %T to url.URL", val)
+	}
+
+	return nil
+}
+
+// assignURLList assigns a list of URLs. Single strings can contain several
+// URLs separated by commas, which are appended to the list.
+func assignURLList(dst *[]url.URL, val interface{}) error {
+	switch val := val.(type) {
+	case []interface{}:
+		*dst = make([]url.URL, len(val))
+		return assignElements(len(val), func(i int) error {
+			return assignURL(&(*dst)[i], val[i])
+		})
+	case []string:
+		*dst = make([]url.URL, len(val))
+		return assignElements(len(val), func(i int) error {
+			return assignURL(&(*dst)[i], val[i])
+		})
+	case []url.URL:
+		*dst = val
+	case string:
+		parts := strings.Split(val, ",")
+		var urls = make([]url.URL, len(parts))
+		if err := assignElements(len(parts), func(i int) error {
+			return assignURL(&urls[i], parts[i])
+		}); err != nil {
+			return err
+		}
+		*dst = append(*dst, urls...)
+	case []byte:
+		return assignURLList(dst, string(val))
+	case url.URL, *url.URL:
+		var u url.URL
+		if err := assignURL(&u, val); err != nil {
+			return err
+		}
+		*dst = append(*dst, u)
+	default:
+		return fmt.Errorf("cannot assign type %T to []url.URL", val)
+	}
+
+	return nil
+}
+
+// splitKeyValue splits a key=value pair.
+func splitKeyValue(val string) (string, string, error) {
+	idx := strings.IndexRune(val, '=')
+	if idx <= 0 {
+		return "", "", fmt.Errorf("invalid key=value pair %q", val)
+	}
+	return val[:idx], val[idx+1:], nil
+}
+
+// assignIntMap assigns a map of ints. Strings must be key=value pairs, which
+// are added to the map.
+func assignIntMap(dst *map[string]int, val interface{}) error {
+	switch val := val.(type) {
+	case map[string]interface{}:
+		var m = make(map[string]int, len(val))
+		for k, v := range val {
+			var n int
+			if err := assignInt(&n, v); err != nil {
+				return fmt.Errorf("invalid value for key %s: %s", k, err)
+			}
+			m[k] = n
+		}
+		*dst = m
+	case map[string]int:
+		*dst = val
+	case []interface{}, []string:
+		var m map[string]int
+		for _, v := range reflectElems(val) {
+			if err := assignIntMap(&m, v); err != nil {
 				return err
 			}
 		}
-	case []string:
-		*dst = make([]time.Duration, len(val))
-		for i, v := range val {
-			if err := assignDuration(&(*dst)[i], v); err != nil {
+		*dst = m
+	case string:
+		k, v, err := splitKeyValue(val)
+		if err != nil {
+			return err
+		}
+
+		var n int
+		if err := assignInt(&n, v); err != nil {
+			return fmt.Errorf("invalid value for key %s: %s", k, err)
+		}
+
+		if *dst == nil {
+			*dst = make(map[string]int)
+		}
+		(*dst)[k] = n
+	case []byte:
+		return assignIntMap(dst, string(val))
+	default:
+		return fmt.Errorf("cannot assign type %T to map[string]int", val)
+	}
+
+	return nil
+}
+
+// assignBoolMap assigns a map of bools. Strings must be key=value pairs,
+// which are added to the map.
+func assignBoolMap(dst *map[string]bool, val interface{}) error {
+	switch val := val.(type) {
+	case map[string]interface{}:
+		var m = make(map[string]bool, len(val))
+		for k, v := range val {
+			var b bool
+			if err := assignBool(&b, v); err != nil {
+				return fmt.Errorf("invalid value for key %s: %s", k, err)
+			}
+			m[k] = b
+		}
+		*dst = m
+	case map[string]bool:
+		*dst = val
+	case []interface{}, []string:
+		var m map[string]bool
+		for _, v := range reflectElems(val) {
+			if err := assignBoolMap(&m, v); err != nil {
 				return err
 			}
 		}
+		*dst = m
+	case string:
+		k, v, err := splitKeyValue(val)
+		if err != nil {
+			return err
+		}
+
+		var b bool
+		if err := assignBool(&b, v); err != nil {
+			return fmt.Errorf("invalid value for key %s: %s", k, err)
+		}
+
+		if *dst == nil {
+			*dst = make(map[string]bool)
+		}
+		(*dst)[k] = b
+	case []byte:
+		return assignBoolMap(dst, string(val))
+	default:
+		return fmt.Errorf("cannot assign type %T to map[string]bool", val)
+	}
+
+	return nil
+}
+
+// reflectElems returns the elements of the given slice.
+func reflectElems(val interface{}) []interface{} {
+	rv := reflect.ValueOf(val)
+	var elems = make([]interface{}, rv.Len())
+	for i := range elems {
+		elems[i] = rv.Index(i).Interface()
+	}
+	return elems
+}
+
+// secondsToDuration converts the bare numbers in the given value, which can
+// be numeric strings or elements of a list, to durations in seconds. The rest
+// of values are returned unchanged.
+func secondsToDuration(val interface{}) interface{} {
+	switch v := val.(type) {
+	case int:
+		return time.Duration(v) * time.Second
+	case int64:
+		return time.Duration(v) * time.Second
+	case uint:
+		return time.Duration(v) * time.Second
+	case uint64:
+		return time.Duration(v) * time.Second
+	case float64:
+		return time.Duration(v * float64(time.Second))
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(f * float64(time.Second))
+		}
+		return v
+	case []byte:
+		return secondsToDuration(string(v))
+	}
+
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice {
+		return val
+	}
+
+	converted := reflectElems(val)
+	for i, v := range converted {
+		converted[i] = secondsToDuration(v)
+	}
+	return converted
+}
+
+// assignDurationList assigns a list of durations converting each element on
+// its own, so mixed lists of strings with units and numbers of nanoseconds
+// are valid.
+func assignDurationList(dst *[]time.Duration, val interface{}) error {
+	switch val := val.(type) {
+	case []interface{}:
+		*dst = make([]time.Duration, len(val))
+		return assignElements(len(val), func(i int) error {
+			return assignDuration(&(*dst)[i], val[i])
+		})
+	case []string:
+		*dst = make([]time.Duration, len(val))
+		return assignElements(len(val), func(i int) error {
+			return assignDuration(&(*dst)[i], val[i])
+		})
 	case []time.Duration:
 		*dst = val
 	case []int:
@@ -537,18 +1089,14 @@ func assignFloat64List(dst *[]float64, val interface{}) error {
 	switch val := val.(type) {
 	case []interface{}:
 		*dst = make([]float64, len(val))
-		for i, v := range val {
-			if err := assignFloat64(&(*dst)[i], v); err != nil {
-				return err
-			}
-		}
+		return assignElements(len(val), func(i int) error {
+			return assignFloat64(&(*dst)[i], val[i])
+		})
 	case []string:
 		*dst = make([]float64, len(val))
-		for i, v := range val {
-			if err := assignFloat64(&(*dst)[i], v); err != nil {
-				return err
-			}
-		}
+		return assignElements(len(val), func(i int) error {
+			return assignFloat64(&(*dst)[i], val[i])
+		})
 	case []float64:
 		*dst = val
 	case []float32:
@@ -589,7 +1137,69 @@ func assignFloat64List(dst *[]float64, val interface{}) error {
 	return nil
 }
 
+// ListError is returned when some of the elements of a list could not be
+// assigned. It contains an error for each one of the invalid elements.
+type ListError []*ElementError
+
+func (e ListError) Error() string {
+	var parts = make([]string, len(e))
+	for i, err := range e {
+		parts[i] = err.Error()
+	}
+	return fmt.Sprintf("invalid list elements: %s", strings.Join(parts, "; "))
+}
+
+// ElementError is the error of a single invalid element of a list.
+type ElementError struct {
+	// Index of the element in the list.
+	Index int
+	// Err is the error returned when assigning the element.
+	Err error
+}
+
+func (e *ElementError) Error() string {
+	return fmt.Sprintf("element %d: %s", e.Index, e.Err)
+}
+
+// assignElements calls assign for each one of the n elements of a list,
+// collecting the errors of all the invalid elements instead of stopping at
+// the first one.
+func assignElements(n int, assign func(i int) error) error {
+	var errs ListError
+	for i := 0; i < n; i++ {
+		if err := assign(i); err != nil {
+			errs = append(errs, &ElementError{i, err})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// isSupported reports whether the given value is of one of the types that
+// can be used in a value created with NewValue.
+func isSupported(v interface{}) bool {
+	switch v.(type) {
+	case string, float64, bool, uint, int, uint64, int64, time.Duration, TimeOfDay, time.Time,
+		[]string, []float64, []int, []uint, []int64, []uint64, []time.Duration,
+		net.IP, []net.IP, url.URL, []url.URL, map[string]int, map[string]bool:
+		return true
+	default:
+		return false
+	}
+}
+
 func isBool(v Value) bool {
+	switch v := v.(type) {
+	case aliasValue:
+		return true
+	case stdValue:
+		return v.isBoolFlag()
+	}
+
 	vb, ok := v.(*value)
 	if !ok {
 		return false
@@ -599,6 +1209,77 @@ func isBool(v Value) bool {
 	return ok
 }
 
+func isString(v Value) bool {
+	vb, ok := v.(*value)
+	if !ok {
+		return false
+	}
+
+	switch vb.value.(type) {
+	case *string, *[]string:
+		return true
+	default:
+		return false
+	}
+}
+
+func isInteger(v Value) bool {
+	vb, ok := v.(*value)
+	if !ok {
+		return false
+	}
+
+	switch vb.value.(type) {
+	case *int, *uint, *int64, *uint64,
+		*[]int, *[]uint, *[]int64, *[]uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+func isFloat(v Value) bool {
+	vb, ok := v.(*value)
+	if !ok {
+		return false
+	}
+
+	switch vb.value.(type) {
+	case *float64, *[]float64:
+		return true
+	default:
+		return false
+	}
+}
+
+func isDuration(v Value) bool {
+	vb, ok := v.(*value)
+	if !ok {
+		return false
+	}
+
+	switch vb.value.(type) {
+	case *time.Duration, *[]time.Duration:
+		return true
+	default:
+		return false
+	}
+}
+
+// clearList empties the given value if it's a list or a map.
+func clearList(v Value) {
+	vb, ok := v.(*value)
+	if !ok {
+		return
+	}
+
+	rv := reflect.ValueOf(vb.value).Elem()
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		rv.Set(reflect.Zero(rv.Type()))
+	}
+}
+
 func isSlice(v Value) bool {
 	vb, ok := v.(*value)
 	if !ok {
@@ -612,7 +1293,9 @@ func isSlice(v Value) bool {
 		*[]uint,
 		*[]int64,
 		*[]uint64,
-		*[]time.Duration:
+		*[]time.Duration,
+		*[]net.IP,
+		*[]url.URL:
 		return true
 	default:
 		return false
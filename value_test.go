@@ -2,6 +2,8 @@ package flagga
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"reflect"
 	"testing"
 	"time"
@@ -45,6 +47,12 @@ func TestValue(t *testing.T) {
 		{new(int), []byte("1"), int(1), false},
 		{new(int), 3.14, int(3), false},
 		{new(int), "asldkjsa", 0, true},
+		{new(int), "1_000_000", int(1000000), false},
+		{new(int), "-1_000", int(-1000), false},
+		{new(int), "1__000", 0, true},
+		{new(int), "_1000", 0, true},
+		{new(int), "1000_", 0, true},
+		{new(int), "1,000", 0, true},
 
 		{new(uint), int(1), uint(1), false},
 		{new(uint), uint(1), uint(1), false},
@@ -54,6 +62,7 @@ func TestValue(t *testing.T) {
 		{new(uint), []byte("1"), uint(1), false},
 		{new(uint), 3.14, uint(3), false},
 		{new(uint), "asldkjsa", 0, true},
+		{new(uint), "1_000", uint(1000), false},
 
 		{new(int64), int(1), int64(1), false},
 		{new(int64), uint(1), int64(1), false},
@@ -63,6 +72,7 @@ func TestValue(t *testing.T) {
 		{new(int64), []byte("1"), int64(1), false},
 		{new(int64), 3.14, int64(3), false},
 		{new(int64), "asldkjsa", 0, true},
+		{new(int64), "1_000", int64(1000), false},
 
 		{new(uint64), int(1), uint64(1), false},
 		{new(uint64), uint(1), uint64(1), false},
@@ -72,6 +82,7 @@ func TestValue(t *testing.T) {
 		{new(uint64), []byte("1"), uint64(1), false},
 		{new(uint64), 3.14, uint64(3), false},
 		{new(uint64), "asldkjsa", 0, true},
+		{new(uint64), "1_000", uint64(1000), false},
 
 		{new(time.Duration), int(1), time.Duration(1), false},
 		{new(time.Duration), uint(1), time.Duration(1), false},
@@ -169,6 +180,8 @@ func TestValue(t *testing.T) {
 		{new([]time.Duration), []string{"1s", "2s"}, []time.Duration{1 * time.Second, 2 * time.Second}, false},
 		{new([]time.Duration), []string{"a", "2"}, nil, true},
 		{new([]time.Duration), []interface{}{0, 1}, []time.Duration{0, 1}, false},
+		{new([]time.Duration), []interface{}{"1s", float64(2000000000), "3m"}, []time.Duration{time.Second, 2 * time.Second, 3 * time.Minute}, false},
+		{new([]time.Duration), []interface{}{"1s", "2"}, nil, true},
 		{new([]time.Duration), "1s", []time.Duration{1 * time.Second}, false},
 		{new([]time.Duration), []byte("1s"), []time.Duration{1 * time.Second}, false},
 		{new([]time.Duration), time.Duration(1), []time.Duration{1}, false},
@@ -226,3 +239,125 @@ func TestValue(t *testing.T) {
 		})
 	}
 }
+
+func TestValueIPAndURLLists(t *testing.T) {
+	mustURL := func(s string) url.URL {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatalf("unexpected error parsing url: %s", err)
+		}
+		return *u
+	}
+
+	var ips []net.IP
+	v := NewValue(&ips)
+	expect(t, v.Set("1.1.1.1"), nil)
+	expect(t, v.Set(" 8.8.8.8 , 8.8.4.4"), nil)
+	expect(t, v.Set(net.ParseIP("::1")), nil)
+	expect(t, ips, []net.IP{
+		net.ParseIP("1.1.1.1"),
+		net.ParseIP("8.8.8.8"),
+		net.ParseIP("8.8.4.4"),
+		net.ParseIP("::1"),
+	})
+
+	expect(t, v.Set([]interface{}{"1.1.1.1", "::1"}), nil)
+	expect(t, ips, []net.IP{net.ParseIP("1.1.1.1"), net.ParseIP("::1")})
+	expect(t, v.Set("1.1.1.1,foo"), ListError{{1, fmt.Errorf(`invalid IP address "foo"`)}})
+
+	var ip net.IP
+	expect(t, NewValue(&ip).Set("10.0.0.1"), nil)
+	expect(t, ip, net.ParseIP("10.0.0.1"))
+	expect(t, NewValue(&ip).Set(1), fmt.Errorf("cannot assign type int to net.IP"))
+
+	var urls []url.URL
+	v = NewValue(&urls)
+	expect(t, v.Set("http://a.com"), nil)
+	expect(t, v.Set("http://b.com,https://c.com/x"), nil)
+	expect(t, urls, []url.URL{
+		mustURL("http://a.com"),
+		mustURL("http://b.com"),
+		mustURL("https://c.com/x"),
+	})
+
+	expect(t, v.Set([]string{"http://d.com"}), nil)
+	expect(t, urls, []url.URL{mustURL("http://d.com")})
+
+	var u url.URL
+	expect(t, NewValue(&u).Set("https://example.com/path?q=1"), nil)
+	expect(t, u, mustURL("https://example.com/path?q=1"))
+}
+
+func TestValueListErrors(t *testing.T) {
+	var dst []int
+	err := NewValue(&dst).Set([]interface{}{"1", "a", 3, "b"})
+	if err == nil {
+		t.Fatalf("expecting error, got nil instead")
+	}
+
+	errs, ok := err.(ListError)
+	if !ok {
+		t.Fatalf("expecting error to be ListError, got %T", err)
+	}
+
+	expect(t, len(errs), 2)
+	expect(t, errs[0].Index, 1)
+	expect(t, errs[1].Index, 3)
+	expect(t, err.Error(), fmt.Sprintf(
+		"invalid list elements: element 1: %s; element 3: %s",
+		errs[0].Err,
+		errs[1].Err,
+	))
+
+	var durations []time.Duration
+	err = NewValue(&durations).Set([]string{"1s", "x", "y"})
+	errs, ok = err.(ListError)
+	if !ok {
+		t.Fatalf("expecting error to be ListError, got %T", err)
+	}
+
+	expect(t, len(errs), 2)
+	expect(t, errs[0].Index, 1)
+	expect(t, errs[1].Index, 2)
+}
+
+func TestValueGetString(t *testing.T) {
+	testCases := []struct {
+		dst      interface{}
+		value    interface{}
+		expected interface{}
+		str      string
+	}{
+		{new(string), "foo", "foo", "foo"},
+		{new(bool), "true", true, "true"},
+		{new(float64), "3.14", 3.14, "3.14"},
+		{new(int), "1", int(1), "1"},
+		{new(uint), "1", uint(1), "1"},
+		{new(int64), "1", int64(1), "1"},
+		{new(uint64), "1", uint64(1), "1"},
+		{new(time.Duration), "1s", time.Second, "1s"},
+		{new([]string), []string{"a", "b"}, []string{"a", "b"}, "[a, b]"},
+		{new([]float64), []string{"1.5", "2"}, []float64{1.5, 2}, "[1.5, 2]"},
+		{new([]int), []string{"1", "2"}, []int{1, 2}, "[1, 2]"},
+		{new([]uint), []string{"1", "2"}, []uint{1, 2}, "[1, 2]"},
+		{new([]int64), []string{"1", "2"}, []int64{1, 2}, "[1, 2]"},
+		{new([]uint64), []string{"1", "2"}, []uint64{1, 2}, "[1, 2]"},
+		{new([]time.Duration), []string{"1s", "2m"}, []time.Duration{time.Second, 2 * time.Minute}, "[1s, 2m0s]"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(fmt.Sprintf("%T", tt.dst), func(t *testing.T) {
+			v := NewValue(tt.dst).(Getter)
+			expect(t, v.Set(tt.value), nil)
+			expect(t, v.Get(), tt.expected)
+			expect(t, v.String(), tt.str)
+
+			// setting the string form again must produce the same value,
+			// except for lists, whose string form is not a single element
+			if !isSlice(v) {
+				expect(t, v.Set(v.String()), nil)
+				expect(t, v.Get(), tt.expected)
+			}
+		})
+	}
+}